@@ -1,31 +1,97 @@
 package sacura
 
 import (
+	"context"
+	"fmt"
+	"log"
 	"sync"
 	"time"
 
 	ce "github.com/cloudevents/sdk-go/v2"
 	"github.com/google/uuid"
 	vegeta "github.com/tsenart/vegeta/v12/lib"
-	"k8s.io/apimachinery/pkg/util/sets"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/global"
 )
 
-func StartSender(config Config, sentOut chan<- ce.Event) Metrics {
+// StartSender starts the sender, its metrics are recorded with the global meter provider.
+func StartSender(config Config, sentOut chan<- ce.Event) (Metrics, error) {
+	return startSender(context.Background(), log.Default(), global.Meter("sacura"), config, sentOut)
+}
+
+// startSender starts the sender, the sender is stopped when ctx is done or when the configured duration is reached.
+//
+// When a scenario is configured, its phases are run one after the other.
+func startSender(ctx context.Context, logger *log.Logger, meter metric.Meter, config Config, sentOut chan<- ce.Event) (Metrics, error) {
+
+	rec, err := newRecorder(logger, config.Sender.Record)
+	if err != nil {
+		return Metrics{}, err
+	}
+	defer func() {
+		if err := rec.Close(); err != nil {
+			logger.Println(err)
+		}
+	}()
+
+	senderMetrics, err := newSenderMetrics(meter)
+	if err != nil {
+		return Metrics{}, err
+	}
+
+	s := &sender{logger: logger, rec: rec, metrics: senderMetrics}
+	if len(config.Sender.Targets) > 0 {
+		s.targets = make(map[string]*Metrics, len(config.Sender.Targets))
+	}
+
+	var metrics Metrics
+	if len(config.Scenario) > 0 {
+		metrics, err = s.runScenario(ctx, config, sentOut)
+	} else {
+		metrics, err = s.attack(ctx, config, "", sentOut)
+	}
+	if err != nil {
+		return Metrics{}, err
+	}
+	metrics.Targets = s.targetMetrics()
+	return metrics, nil
+}
+
+// sender holds what's shared by the attacks of a run.
+type sender struct {
+	logger  *log.Logger
+	rec     *recorder
+	metrics *senderMetrics
+	// total are the metrics of every attack of the run.
+	total vegeta.Metrics
+	// targets are the metrics of every attack of the run by target, they're only collected with multiple targets.
+	targets map[string]*Metrics
+}
+
+// attack sends events until ctx is done or until the configured duration is reached, events are stamped with the
+// given phase when it isn't empty.
+func (s *sender) attack(ctx context.Context, config Config, phase string, sentOut chan<- ce.Event) (Metrics, error) {
+	rec := s.rec
+
+	pacer, err := NewPacer(config.Sender)
+	if err != nil {
+		return Metrics{}, fmt.Errorf("failed to create pacer: %w", err)
+	}
 
-	rate := vegeta.Rate{
-		Freq: config.Sender.FrequencyPerSecond,
-		Per:  time.Second,
+	opts, err := attackerOptions(config.Sender)
+	if err != nil {
+		return Metrics{}, fmt.Errorf("failed to create attacker: %w", err)
 	}
 
 	proposedCount := 0
 	proposed := make(chan ce.Event, cap(sentOut))
-	accepted := make(chan string, cap(sentOut))
+	accepted := make(chan acceptedEvent, cap(sentOut))
 	var wg sync.WaitGroup
 	wg.Add(2)
 
 	go func() {
 		proposedArr := make(map[string]ce.Event, 100)
-		acceptedArr := sets.NewString()
+		acceptedArr := make(map[string]time.Time, 100)
 		var m sync.Mutex
 
 		go func() {
@@ -38,10 +104,11 @@ func StartSender(config Config, sentOut chan<- ce.Event) Metrics {
 
 					proposedCount++
 					proposedArr[e.ID()] = e
-					if acceptedArr.Has(e.ID()) {
+					if t, ok := acceptedArr[e.ID()]; ok {
+						rec.recordAccepted(&e, t)
 						sentOut <- e
 						delete(proposedArr, e.ID())
-						acceptedArr.Delete(e.ID())
+						delete(acceptedArr, e.ID())
 					}
 
 				}()
@@ -51,41 +118,65 @@ func StartSender(config Config, sentOut chan<- ce.Event) Metrics {
 		go func() {
 			defer wg.Done()
 
-			for id := range accepted {
+			for a := range accepted {
 				func() {
 					m.Lock()
 					defer m.Unlock()
 
-					acceptedArr.Insert(id)
-					if v, ok := proposedArr[id]; ok {
+					acceptedArr[a.id] = a.time
+					if v, ok := proposedArr[a.id]; ok {
+						rec.recordAccepted(&v, a.time)
 						sentOut <- v
-						delete(proposedArr, id)
-						acceptedArr.Delete(id)
+						delete(proposedArr, a.id)
+						delete(acceptedArr, a.id)
 					}
 				}()
 			}
 		}()
 	}()
 
-	targeter := NewTargeterGenerator(config, uuid.New, proposed)
+	targeter := newTargeterGenerator(s.logger, config, phase, uuid.New, proposed)
+
+	attacker := vegeta.NewAttacker(opts...)
 
-	attacker := vegeta.NewAttacker(
-		vegeta.Workers(config.Sender.Workers),
-		vegeta.KeepAlive(config.Sender.KeepAlive),
-		vegeta.MaxWorkers(config.Sender.Workers),
-	)
+	attackDone := make(chan struct{})
+	defer close(attackDone)
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.logger.Println("Stopping sender ...")
+			attacker.Stop()
+		case <-attackDone:
+		}
+	}()
 
 	var metrics vegeta.Metrics
 	var acceptedCount int
+	var rejectedIDs []string
+	var uncertainIDs []string
 
-	for res := range attacker.Attack(targeter, rate, config.ParsedDuration, "Sacura") {
+	for res := range attacker.Attack(targeter, pacer, config.ParsedDuration, "Sacura") {
+		if isSourceExhausted(res) {
+			// The attack is stopped because all the events of the source have been sent, no request was sent.
+			continue
+		}
 		metrics.Add(res)
-		if res.Error == "" && res.Code >= 200 && res.Code < 300 {
-			acceptedCount++
-			id := res.RequestHeaders.Get(CloudEventIdHeader)
-			if id != "" {
-				accepted <- id
+		s.total.Add(res)
+		s.addTargetResult(res)
+		s.metrics.record(context.Background(), res)
+		if isAccepted(res) {
+			// A single request carries multiple events when sending batches.
+			for _, id := range res.RequestHeaders.Values(CloudEventIdHeader) {
+				acceptedCount++
+				accepted <- acceptedEvent{id: id, time: res.Timestamp.Add(res.Latency)}
 			}
+		} else if res.Code != 0 {
+			// The system under test responded, so these events must not be delivered.
+			rejectedIDs = append(rejectedIDs, res.RequestHeaders.Values(CloudEventIdHeader)...)
+		} else {
+			// The request failed without a response, for example because of a timeout, so these events might have
+			// been accepted.
+			uncertainIDs = append(uncertainIDs, res.RequestHeaders.Values(CloudEventIdHeader)...)
 		}
 	}
 	metrics.Close()
@@ -94,8 +185,48 @@ func StartSender(config Config, sentOut chan<- ce.Event) Metrics {
 	wg.Wait()
 
 	return Metrics{
-		ProposedCount: proposedCount,
-		AcceptedCount: acceptedCount,
-		Metrics:       metrics,
+		ProposedCount:  proposedCount,
+		AcceptedCount:  acceptedCount,
+		RejectedCount:  len(rejectedIDs),
+		RejectedIDs:    rejectedIDs,
+		UncertainCount: len(uncertainIDs),
+		UncertainIDs:   uncertainIDs,
+		Metrics:        metrics,
+	}, nil
+}
+
+func attackerOptions(config SenderConfig) ([]func(*vegeta.Attacker), error) {
+	opts := []func(*vegeta.Attacker){
+		vegeta.Workers(config.Workers),
+		vegeta.KeepAlive(config.KeepAlive),
+		vegeta.MaxWorkers(config.Workers),
+	}
+
+	tlsConfig := vegeta.DefaultTLSConfig.Clone()
+	if config.TLS != nil {
+		var err error
+		if tlsConfig, err = config.TLS.tlsConfig(); err != nil {
+			return nil, err
+		}
 	}
+	// Enabling HTTP/2 changes the TLS configuration, so it's always a copy of the default one.
+	opts = append(opts, vegeta.TLSConfig(tlsConfig), vegeta.HTTP2(config.HTTP2))
+
+	return opts, nil
+}
+
+// isSourceExhausted returns whether the given result reports that the event source has no more events.
+func isSourceExhausted(res *vegeta.Result) bool {
+	return res.Code == 0 && res.Error == vegeta.ErrNoTargets.Error()
+}
+
+// isAccepted returns whether the events sent in the request of the given result have been accepted.
+func isAccepted(res *vegeta.Result) bool {
+	return res.Error == "" && res.Code >= 200 && res.Code < 300
+}
+
+// acceptedEvent is the ID of an event accepted at the given time.
+type acceptedEvent struct {
+	id   string
+	time time.Time
 }
@@ -0,0 +1,208 @@
+package sacura
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultTokenRefreshInterval is the default interval between reads of SenderAuthConfig.TokenFile.
+	DefaultTokenRefreshInterval = time.Minute
+
+	redacted = "<redacted>"
+)
+
+// MarshalJSON redacts secrets, so that they aren't logged with the configuration.
+func (a SenderAuthConfig) MarshalJSON() ([]byte, error) {
+	type plain SenderAuthConfig
+	p := plain(a)
+	if p.BearerToken != "" {
+		p.BearerToken = redacted
+	}
+	if p.Basic != nil {
+		basic := *p.Basic
+		if basic.Password != "" {
+			basic.Password = redacted
+		}
+		p.Basic = &basic
+	}
+	if len(p.Headers) > 0 {
+		headers := make(map[string]string, len(p.Headers))
+		for name := range p.Headers {
+			headers[name] = redacted
+		}
+		p.Headers = headers
+	}
+	return json.Marshal(p)
+}
+
+// MarshalJSON redacts the static token, so that it isn't logged with the configuration.
+func (a ReceiverAuthConfig) MarshalJSON() ([]byte, error) {
+	type plain ReceiverAuthConfig
+	p := plain(a)
+	if p.BearerToken != "" {
+		p.BearerToken = redacted
+	}
+	return json.Marshal(p)
+}
+
+// authenticator sets the authentication headers of requests to targets, it's safe for concurrent use.
+//
+// A nil authenticator doesn't set any header.
+type authenticator struct {
+	logger *log.Logger
+	config *SenderAuthConfig
+
+	lock     sync.Mutex
+	token    string
+	readTime time.Time
+}
+
+// newAuthenticator creates an authenticator for the given configuration, it returns a nil authenticator when config
+// is nil.
+//
+// Token refresh failures are logged with logger.
+func newAuthenticator(logger *log.Logger, config *SenderAuthConfig) (*authenticator, error) {
+	if config == nil {
+		return nil, nil
+	}
+	a := &authenticator{logger: logger, config: config, token: config.BearerToken}
+	if config.TokenFile != "" {
+		token, err := readToken(config.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+		a.token = token
+		a.readTime = time.Now()
+	}
+	return a, nil
+}
+
+func (a *authenticator) setHeaders(hdr http.Header) {
+	if a == nil {
+		return
+	}
+	for name, value := range a.config.Headers {
+		hdr.Set(name, value)
+	}
+	if a.config.Basic != nil {
+		// http.Header has no helper for basic auth, a request is used to encode credentials.
+		req := http.Request{Header: hdr}
+		req.SetBasicAuth(a.config.Basic.Username, a.config.Basic.Password)
+		return
+	}
+	if token := a.currentToken(); token != "" {
+		hdr.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// currentToken returns the bearer token, the token file is read again when the refresh interval is elapsed.
+func (a *authenticator) currentToken() string {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.config.TokenFile != "" && time.Since(a.readTime) >= a.config.ParsedTokenRefreshInterval {
+		a.readTime = time.Now()
+		token, err := readToken(a.config.TokenFile)
+		if err != nil {
+			// The previous token might still be valid, keep it.
+			a.logger.Println("failed to refresh token", err)
+		} else {
+			a.token = token
+		}
+	}
+	return a.token
+}
+
+func readToken(path string) (string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token file: %w", err)
+	}
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return "", errors.New("token file is empty")
+	}
+	return token, nil
+}
+
+// AuthRejection is the reason the receiver rejected a request.
+type AuthRejection string
+
+const (
+	// MissingTokenRejection is reported when the request doesn't have a bearer token.
+	MissingTokenRejection AuthRejection = "missingToken"
+	// InvalidTokenRejection is reported when the bearer token doesn't match the static token.
+	InvalidTokenRejection AuthRejection = "invalidToken"
+	// MalformedTokenRejection is reported when the bearer token isn't a JWT.
+	MalformedTokenRejection AuthRejection = "malformedToken"
+	// InvalidSignatureRejection is reported when the JWT isn't signed by a key in the JWKS file.
+	InvalidSignatureRejection AuthRejection = "invalidSignature"
+	// ExpiredTokenRejection is reported when the JWT is expired or not yet valid.
+	ExpiredTokenRejection AuthRejection = "expiredToken"
+	// InvalidIssuerRejection is reported when the JWT issuer isn't the configured issuer.
+	InvalidIssuerRejection AuthRejection = "invalidIssuer"
+	// InvalidAudienceRejection is reported when the JWT audience doesn't contain the configured audience.
+	InvalidAudienceRejection AuthRejection = "invalidAudience"
+)
+
+// tokenVerifier verifies the bearer tokens presented to the receiver.
+//
+// A nil tokenVerifier accepts every request.
+type tokenVerifier struct {
+	config *ReceiverAuthConfig
+	keys   *jwks
+}
+
+// newTokenVerifier creates a tokenVerifier for the given configuration, it returns a nil tokenVerifier when config is
+// nil.
+func newTokenVerifier(config *ReceiverAuthConfig) (*tokenVerifier, error) {
+	if config == nil {
+		return nil, nil
+	}
+	v := &tokenVerifier{config: config}
+	if config.JWT != nil {
+		keys, err := loadJWKS(config.JWT.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		v.keys = keys
+	}
+	return v, nil
+}
+
+// verify verifies the bearer token of the given request, it returns an empty AuthRejection when the request is
+// authorized.
+func (v *tokenVerifier) verify(r *http.Request) AuthRejection {
+	if v == nil {
+		return ""
+	}
+	token := bearerToken(r)
+	if token == "" {
+		return MissingTokenRejection
+	}
+	if v.config.JWT != nil {
+		return verifyJWT(token, v.keys, v.config.JWT, time.Now())
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(v.config.BearerToken)) != 1 {
+		return InvalidTokenRejection
+	}
+	return ""
+}
+
+func bearerToken(r *http.Request) string {
+	const prefix = "bearer "
+	h := r.Header.Get("Authorization")
+	if len(h) <= len(prefix) || !strings.EqualFold(h[:len(prefix)], prefix) {
+		return ""
+	}
+	return strings.TrimSpace(h[len(prefix):])
+}
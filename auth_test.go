@@ -0,0 +1,132 @@
+package sacura
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAuthenticator(t *testing.T) {
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("file-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		config *SenderAuthConfig
+		want   http.Header
+	}{
+		{
+			name:   "no auth",
+			config: nil,
+			want:   http.Header{},
+		},
+		{
+			name:   "bearer token",
+			config: &SenderAuthConfig{BearerToken: "token"},
+			want:   http.Header{"Authorization": []string{"Bearer token"}},
+		},
+		{
+			name:   "token file",
+			config: &SenderAuthConfig{TokenFile: tokenFile, TokenRefreshInterval: "1h", ParsedTokenRefreshInterval: time.Hour},
+			want:   http.Header{"Authorization": []string{"Bearer file-token"}},
+		},
+		{
+			name:   "basic",
+			config: &SenderAuthConfig{Basic: &BasicAuthConfig{Username: "user", Password: "pass"}},
+			want:   http.Header{"Authorization": []string{"Basic dXNlcjpwYXNz"}},
+		},
+		{
+			name: "headers",
+			config: &SenderAuthConfig{
+				BearerToken: "token",
+				Headers:     map[string]string{"x-api-key": "key"},
+			},
+			want: http.Header{
+				"Authorization": []string{"Bearer token"},
+				"X-Api-Key":     []string{"key"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := newAuthenticator(log.Default(), tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := http.Header{}
+			a.setHeaders(got)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("(-want, +got) %s", diff)
+			}
+		})
+	}
+}
+
+func TestAuthenticatorTokenRefresh(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := newAuthenticator(log.Default(), &SenderAuthConfig{TokenFile: tokenFile, TokenRefreshInterval: "50ms", ParsedTokenRefreshInterval: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(tokenFile, []byte("new"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if got := a.currentToken(); got != "old" {
+		t.Errorf("want token old before the refresh interval, got %s", got)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if got := a.currentToken(); got != "new" {
+		t.Errorf("want token new after the refresh interval, got %s", got)
+	}
+
+	if err := os.Remove(tokenFile); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if got := a.currentToken(); got != "new" {
+		t.Errorf("want previous token new when the token file can't be read, got %s", got)
+	}
+}
+
+func TestSenderAuthConfigMarshalJSON(t *testing.T) {
+	config := SenderConfig{
+		Auth: &SenderAuthConfig{
+			BearerToken: "token",
+			Basic:       &BasicAuthConfig{Username: "user", Password: "pass"},
+			Headers:     map[string]string{"X-Api-Key": "key"},
+		},
+	}
+
+	b, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"token", "pass", "key"} {
+		if strings.Contains(string(b), `"`+secret+`"`) {
+			t.Errorf("want %s redacted, got %s", secret, string(b))
+		}
+	}
+	if !strings.Contains(string(b), `"user"`) {
+		t.Errorf("want username, got %s", string(b))
+	}
+	if config.Auth.BearerToken != "token" || config.Auth.Basic.Password != "pass" || config.Auth.Headers["X-Api-Key"] != "key" {
+		t.Errorf("want configuration unchanged, got %+v", config.Auth)
+	}
+}
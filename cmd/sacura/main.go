@@ -2,11 +2,13 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 
 	_ "go.uber.org/automaxprocs"
 
@@ -14,12 +16,53 @@ import (
 )
 
 const (
-	filePathFlag = "config"
+	filePathFlag     = "config"
+	reportFileFlag   = "report-file"
+	reportFormatFlag = "report-format"
+	setFlag          = "set"
+
+	reconcileCommand = "reconcile"
+
+	controlCommand = "control"
+	addressFlag    = "address"
+	tokenFileFlag  = "token-file"
+
+	validateCommand = "validate"
+	schemaCommand   = "schema"
 )
 
 func main() {
 
+	if len(os.Args) > 1 && os.Args[1] == reconcileCommand {
+		if err := reconcile(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == controlCommand {
+		if err := control(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == validateCommand {
+		if err := validate(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == schemaCommand {
+		if err := schema(); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	path := flag.String(filePathFlag, "", "Path to the configuration file")
+	reportFile := flag.String(reportFileFlag, "", "Path to the file the report is written to, it overrides report.file")
+	reportFormat := flag.String(reportFormatFlag, "", "Format of the report file (json, yaml, markdown, junit), it overrides report.format")
+	var sets setFlags
+	flag.Var(&sets, setFlag, setUsage)
 	flag.Parse()
 
 	if path == nil || *path == "" {
@@ -28,33 +71,209 @@ func main() {
 		return
 	}
 
-	if err := run(*path); err != nil {
+	if err := run(*path, *reportFile, *reportFormat, sets); err != nil {
 		log.Fatal(err)
 	}
 }
 
 func usage() {
 	log.Printf(`
-sacura --%s <absolute_path_to_config_file>
-`, filePathFlag)
+sacura --%s <absolute_path_to_config_file> [--%s <path_to_report_file>] [--%s <json|yaml|markdown|junit>] [--%s <path>=<value>]...
+sacura %s --%s <absolute_path_to_config_file> [--%s <path_to_report_file>] [--%s <json|yaml|markdown|junit>] [--%s <path>=<value>]... <state_file>...
+sacura %s [--%s <control_server_address>] [--%s <path_to_bearer_token_file>]
+sacura %s --%s <absolute_path_to_config_file> [--%s <path>=<value>]...
+sacura %s
+
+Configuration fields are overridden by %s* environment variables, for example %sSENDER_FREQUENCY=500, and by
+--%s flags, for example --%s sender.frequency=500. Flags take precedence over environment variables, which take
+precedence over the configuration file.
+`, filePathFlag, reportFileFlag, reportFormatFlag, setFlag, reconcileCommand, filePathFlag, reportFileFlag, reportFormatFlag, setFlag,
+		controlCommand, addressFlag, tokenFileFlag, validateCommand, filePathFlag, setFlag, schemaCommand,
+		sacura.EnvOverridePrefix, sacura.EnvOverridePrefix, setFlag, setFlag)
+}
+
+func run(path, reportFile, reportFormat string, sets setFlags) error {
+
+	config, err := readConfig(path, reportFile, reportFormat, sets)
+	if err != nil {
+		return err
+	}
+
+	return sacura.Main(NewContext(), config)
 }
 
-func run(path string) error {
+// reconcile reconciles the state files exported by the sender and the receivers of a distributed run.
+func reconcile(args []string) error {
+
+	fs := flag.NewFlagSet(reconcileCommand, flag.ExitOnError)
+	path := fs.String(filePathFlag, "", "Path to the configuration file")
+	reportFile := fs.String(reportFileFlag, "", "Path to the file the report is written to, it overrides report.file")
+	reportFormat := fs.String(reportFormatFlag, "", "Format of the report file (json, yaml, markdown, junit), it overrides report.format")
+	var sets setFlags
+	fs.Var(&sets, setFlag, setUsage)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *path == "" || fs.NArg() == 0 {
+		usage()
+		return fmt.Errorf("%s requires the --%s flag and at least one state file", reconcileCommand, filePathFlag)
+	}
+
+	config, err := readConfig(*path, *reportFile, *reportFormat, sets)
+	if err != nil {
+		return err
+	}
+
+	states := make([]sacura.State, 0, fs.NArg())
+	for _, p := range fs.Args() {
+		state, err := readState(p)
+		if err != nil {
+			return err
+		}
+		states = append(states, state)
+	}
+
+	return sacura.ReconcileMain(config, states)
+}
+
+// control starts the control server, runs are started, inspected and stopped through its HTTP API.
+func control(args []string) error {
+
+	fs := flag.NewFlagSet(controlCommand, flag.ExitOnError)
+	address := fs.String(addressFlag, "127.0.0.1:8080", "Address the control server listens on")
+	tokenFile := fs.String(tokenFileFlag, "", "Path to the file with the bearer token requests must carry, when it isn't set requests aren't authenticated")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var token string
+	if *tokenFile != "" {
+		b, err := os.ReadFile(*tokenFile)
+		if err != nil {
+			return fmt.Errorf("failed to read token file %s: %w", *tokenFile, err)
+		}
+		token = strings.TrimSpace(string(b))
+		if token == "" {
+			return fmt.Errorf("token file %s is empty", *tokenFile)
+		}
+	}
+
+	return sacura.StartControlServer(NewContext(), *address, token)
+}
+
+// validate validates the configuration file without starting a run.
+func validate(args []string) error {
+
+	fs := flag.NewFlagSet(validateCommand, flag.ExitOnError)
+	path := fs.String(filePathFlag, "", "Path to the configuration file")
+	var sets setFlags
+	fs.Var(&sets, setFlag, setUsage)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *path == "" {
+		usage()
+		return fmt.Errorf("%s requires the --%s flag", validateCommand, filePathFlag)
+	}
+
+	config, err := readConfig(*path, "", "", sets)
+	if err != nil {
+		return err
+	}
+	c, err := json.Marshal(&config)
+	if err != nil {
+		return err
+	}
+	log.Printf("Configuration file %s is valid, effective configuration %s", *path, string(c))
+	return nil
+}
+
+// schema writes the JSON Schema of the configuration file to stdout.
+func schema() error {
+	b, err := sacura.ConfigJSONSchema()
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(b)
+	return err
+}
+
+func readState(path string) (sacura.State, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return sacura.State{}, fmt.Errorf("failed to open file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	state, err := sacura.ReadState(f)
+	if err != nil {
+		return sacura.State{}, fmt.Errorf("failed to read state from file %s: %w", path, err)
+	}
+	return state, nil
+}
+
+// readConfig reads the configuration file, fields are overridden by environment variables and then by --set flags.
+func readConfig(path, reportFile, reportFormat string, sets setFlags) (sacura.Config, error) {
 
 	log.Println("Reading configuration ...")
 
+	overrides := sacura.EnvOverrides(os.Environ())
+	for _, s := range sets {
+		o, err := sacura.ParseOverride(s, "flag --"+setFlag)
+		if err != nil {
+			return sacura.Config{}, err
+		}
+		overrides = append(overrides, o)
+	}
+	for _, o := range overrides {
+		// Values aren't logged, since they might be secrets.
+		log.Println("Overriding", o)
+	}
+
 	f, err := os.Open(path)
 	if err != nil {
-		return fmt.Errorf("failed to open file %s: %w", path, err)
+		return sacura.Config{}, fmt.Errorf("failed to open file %s: %w", path, err)
 	}
 	defer f.Close()
 
-	config, err := sacura.FileConfig(f)
+	config, err := sacura.FileConfig(f, overrides...)
 	if err != nil {
-		return fmt.Errorf("failef to read config from file %s: %w", path, err)
+		return sacura.Config{}, fmt.Errorf("failef to read config from file %s: %w", path, err)
 	}
 
-	return sacura.Main(NewContext(), config)
+	if reportFile != "" || reportFormat != "" {
+		if config.Report == nil {
+			config.Report = &sacura.ReportConfig{}
+		}
+		if reportFile != "" {
+			config.Report.File = reportFile
+		}
+		if reportFormat != "" {
+			format, err := sacura.ParseReportFormat(reportFormat)
+			if err != nil {
+				return sacura.Config{}, fmt.Errorf("invalid flag %s: %w", reportFormatFlag, err)
+			}
+			config.Report.Format = format
+		}
+	}
+
+	return config, nil
+}
+
+const setUsage = "Override of a configuration field in the form <path>=<value>, for example sender.frequency=500, it can be repeated"
+
+// setFlags collects the values of the repeated --set flag.
+type setFlags []string
+
+func (s *setFlags) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *setFlags) Set(v string) error {
+	*s = append(*s, v)
+	return nil
 }
 
 // NewContext creates a new context with signal handling.
@@ -5,11 +5,20 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
+	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
 	"time"
 
+	"github.com/cloudevents/sdk-go/v2/event"
 	"github.com/go-yaml/yaml"
 	vegeta "github.com/tsenart/vegeta/v12/lib"
+	"k8s.io/apimachinery/pkg/util/sets"
 )
 
 type Config struct {
@@ -20,22 +29,351 @@ type Config struct {
 	Duration string         `json:"duration" yaml:"duration"`
 	Ordered  *OrderedConfig `json:"ordered" yaml:"ordered"`
 
-	ParsedDuration time.Duration
+	// Integrity enables payload integrity verification of received events.
+	Integrity *IntegrityConfig `json:"integrity" yaml:"integrity"`
+
+	// RunID identifies the run, events received from other runs aren't considered received.
+	//
+	// When it isn't specified, a random run ID is generated if the sender is enabled, otherwise events from any run
+	// are considered received.
+	RunID string `json:"runId" yaml:"runId"`
+
+	// DeliveryGuarantee is the delivery guarantee of the system under test, it defaults to AtLeastOnce.
+	DeliveryGuarantee DeliveryGuarantee `json:"deliveryGuarantee" yaml:"deliveryGuarantee"`
+
+	Report *ReportConfig `json:"report" yaml:"report"`
+
+	Metrics MetricsConfig `json:"metrics" yaml:"metrics"`
+
+	// Scenario is a list of phases run one after the other, each phase overrides part of the sender configuration and
+	// the receiver fault configuration.
+	//
+	// When Scenario is specified, Duration is ignored and the duration of the run is the total duration of the phases
+	// and pauses.
+	Scenario []PhaseConfig `json:"scenario" yaml:"scenario"`
+
+	// StateFile is the path of the file the state of the run is exported to, it's used in distributed mode to
+	// reconcile the states of a sender-only instance and of one or more receiver-only instances into one report.
+	//
+	// RunID is required when StateFile is specified, since it must be the same for every instance.
+	StateFile string `json:"stateFile" yaml:"stateFile"`
+
+	ParsedDuration time.Duration `json:"-" yaml:"-"`
+}
+
+// PhaseConfig configures a phase of a scenario, sender settings that aren't specified are inherited from the sender
+// configuration.
+type PhaseConfig struct {
+	// Name identifies the phase in the report, it defaults to phase-<n> where n is the position of the phase in the
+	// scenario starting from 1.
+	Name string `json:"name" yaml:"name"`
+	// Duration is the duration of the phase.
+	Duration string `json:"duration" yaml:"duration"`
+	// Pause is the time to wait after the phase before starting the next phase.
+	Pause string `json:"pause" yaml:"pause"`
+
+	Target string `json:"target" yaml:"target"`
+	// Frequency overrides both the sender frequency and profile.
+	Frequency int            `json:"frequency" yaml:"frequency"`
+	Profile   *ProfileConfig `json:"profile" yaml:"profile"`
+	Event     *EventConfig   `json:"event" yaml:"event"`
+
+	// Fault is the receiver fault configuration for requests carrying events sent during the phase, when it isn't
+	// specified the receiver fault configuration is used.
+	Fault *ReceiverFaultConfig `json:"fault" yaml:"fault"`
+
+	ParsedDuration time.Duration `json:"-" yaml:"-"`
+	ParsedPause    time.Duration `json:"-" yaml:"-"`
+}
+
+// MetricsConfig configures the Prometheus metrics endpoint.
+type MetricsConfig struct {
+	// Disabled disables the metrics endpoint, metrics are neither exposed nor logged at the end of the run.
+	Disabled bool `json:"disabled" yaml:"disabled"`
+	// Address is the address the metrics endpoint listens on, it defaults to DefaultMetricsAddress.
+	//
+	// Use port 0 to listen on a random free port.
+	Address string `json:"address" yaml:"address"`
+	// HistogramBoundaries are the boundaries of the latency histograms in milliseconds, it defaults to
+	// DefaultHistogramBoundaries.
+	HistogramBoundaries []float64 `json:"histogramBoundaries" yaml:"histogramBoundaries"`
+}
+
+// ReportConfig configures where the final report is written in addition to the log.
+type ReportConfig struct {
+	// File is the path of the file the report is written to.
+	File string `json:"file" yaml:"file"`
+	// Format is the format of the report file, it defaults to JSONReportFormat.
+	Format ReportFormat `json:"format" yaml:"format"`
+}
+
+type DeliveryGuarantee string
+
+const (
+	// AtLeastOnce doesn't allow lost events, duplicates are allowed up to receiver.maxDuplicatesPercentage.
+	AtLeastOnce DeliveryGuarantee = "atLeastOnce"
+	// AtMostOnce allows lost events but doesn't allow duplicates.
+	AtMostOnce DeliveryGuarantee = "atMostOnce"
+	// ExactlyOnce allows neither lost events nor duplicates.
+	ExactlyOnce DeliveryGuarantee = "exactlyOnce"
+)
+
+func (d DeliveryGuarantee) AllowsLoss() bool {
+	return d == AtMostOnce
+}
+
+func (d DeliveryGuarantee) AllowsDuplicates() bool {
+	return d == AtLeastOnce
 }
 
 type OrderedConfig struct {
 	NumPartitionKeys uint8 `json:"numPartitionKeys" yaml:"numPartitionKeys"`
+	// MaxOrderingViolations is the maximum number of events received out of order across all partitions.
+	//
+	// When it isn't specified, ordering violations are reported but they don't fail the run.
+	MaxOrderingViolations *int `json:"maxOrderingViolations" yaml:"maxOrderingViolations"`
+}
+
+// IntegrityConfig configures payload integrity verification.
+//
+// The sender stamps each event with checksums of its data and attributes and the receiver verifies them.
+type IntegrityConfig struct {
+	// MaxViolations is the maximum number of received events failing the integrity verification.
+	//
+	// When it isn't specified, integrity violations are reported but they don't fail the run.
+	MaxViolations *int `json:"maxViolations" yaml:"maxViolations"`
 }
 
 type SenderConfig struct {
-	Disabled           bool   `json:"disabled" yaml:"disabled"`
-	Target             string `json:"target" yaml:"target"`
+	Disabled bool   `json:"disabled" yaml:"disabled"`
+	Target   string `json:"target" yaml:"target"`
+	// Targets are the targets requests are sent to, each request is sent to a target picked according to
+	// TargetSelection.
+	//
+	// Target and Targets cannot be both specified.
+	Targets []TargetConfig `json:"targets" yaml:"targets"`
+	// TargetSelection is how targets are picked, it defaults to WeightedTargetSelection.
+	TargetSelection TargetSelection `json:"targetSelection" yaml:"targetSelection"`
+
 	FrequencyPerSecond int    `json:"frequency" yaml:"frequency"`
 	Workers            uint64 `json:"workers" yaml:"workers"`
 	KeepAlive          bool   `json:"keepAlive" yaml:"keepAlive"`
+
+	// Profile is the traffic shape, when it isn't specified, events are sent at a constant rate of FrequencyPerSecond.
+	Profile *ProfileConfig `json:"profile" yaml:"profile"`
+
+	Event *EventConfig `json:"event" yaml:"event"`
+	// Source is the source of the events sent, when it isn't specified events are generated according to Event.
+	Source *SourceConfig `json:"source" yaml:"source"`
+
+	// Encoding is the encoding of the events sent, it defaults to StructuredEncoding.
+	Encoding Encoding `json:"encoding" yaml:"encoding"`
+	// BatchSize is the number of events sent in a single request when Encoding is BatchEncoding.
+	//
+	// When Encoding is BatchEncoding, FrequencyPerSecond is the number of requests per second.
+	BatchSize int `json:"batchSize" yaml:"batchSize"`
+
+	// Record records every accepted event to a file.
+	Record *RecordConfig `json:"record" yaml:"record"`
+
+	// TLS configures connections to HTTPS targets, when it isn't specified the certificates of targets aren't
+	// verified.
+	TLS *SenderTLSConfig `json:"tls" yaml:"tls"`
+	// HTTP2 enables HTTP/2, it's negotiated with TLS so every target must be an HTTPS URL.
+	HTTP2 bool `json:"http2" yaml:"http2"`
+
+	// Auth authenticates requests to targets.
+	Auth *SenderAuthConfig `json:"auth" yaml:"auth"`
+}
+
+// SenderAuthConfig configures the authentication of requests to targets, only one of BearerToken, TokenFile and
+// Basic can be specified.
+type SenderAuthConfig struct {
+	// BearerToken is a static token sent as bearer token.
+	BearerToken string `json:"bearerToken" yaml:"bearerToken"`
+	// TokenFile is the path of a file holding a token sent as bearer token, the file is read again every
+	// TokenRefreshInterval so that rotated tokens, like projected service account tokens, are picked up.
+	TokenFile string `json:"tokenFile" yaml:"tokenFile"`
+	// TokenRefreshInterval is the interval between reads of TokenFile, it defaults to DefaultTokenRefreshInterval.
+	TokenRefreshInterval string `json:"tokenRefreshInterval" yaml:"tokenRefreshInterval"`
+	// Basic sends basic authentication credentials.
+	Basic *BasicAuthConfig `json:"basic" yaml:"basic"`
+
+	// Headers are static headers added to every request, like API keys.
+	//
+	// The Authorization header can't be specified when BearerToken, TokenFile or Basic is specified.
+	Headers map[string]string `json:"headers" yaml:"headers"`
+
+	ParsedTokenRefreshInterval time.Duration `json:"-" yaml:"-"`
+}
+
+type BasicAuthConfig struct {
+	Username string `json:"username" yaml:"username"`
+	Password string `json:"password" yaml:"password"`
+}
+
+// SenderTLSConfig configures connections to HTTPS targets.
+type SenderTLSConfig struct {
+	// CAFile is the path of a PEM bundle of the CAs verifying the certificates of targets, it defaults to the system
+	// CAs.
+	CAFile string `json:"caFile" yaml:"caFile"`
+	// CertFile and KeyFile are the paths of the PEM client certificate and key presented to targets requesting a
+	// client certificate, they must be both specified or both empty.
+	CertFile string `json:"certFile" yaml:"certFile"`
+	KeyFile  string `json:"keyFile" yaml:"keyFile"`
+	// InsecureSkipVerify disables the verification of the certificates of targets.
+	InsecureSkipVerify bool `json:"insecureSkipVerify" yaml:"insecureSkipVerify"`
+}
+
+// TargetConfig configures one of multiple targets.
+type TargetConfig struct {
+	URL string `json:"url" yaml:"url"`
+	// Weight is the weight of the target relative to the other targets, it defaults to 1.
+	//
+	// It's ignored when TargetSelection is RoundRobinTargetSelection.
+	Weight int `json:"weight" yaml:"weight"`
+}
+
+type TargetSelection string
+
+const (
+	// WeightedTargetSelection picks targets at random with a probability proportional to their weight.
+	WeightedTargetSelection TargetSelection = "weighted"
+	// RoundRobinTargetSelection picks targets one after the other in the configured order.
+	RoundRobinTargetSelection TargetSelection = "roundRobin"
+)
+
+// SourceConfig configures the source of the events sent.
+type SourceConfig struct {
+	File *FileSourceConfig `json:"file" yaml:"file"`
+}
+
+func (s *SourceConfig) file() *FileSourceConfig {
+	if s == nil {
+		return nil
+	}
+	return s.File
+}
+
+// FileSourceConfig replays events read from a file, events are sent using the configured sender encoding.
+type FileSourceConfig struct {
+	// Path is the path of the file.
+	Path string `json:"path" yaml:"path"`
+	// Format is the format of the file, it defaults to CloudEventsSourceFormat.
+	Format SourceFormat `json:"format" yaml:"format"`
+	// Loop replays the file from the beginning once all the events have been sent, when it's false the sender stops
+	// once all the events have been sent.
+	//
+	// When Loop is true, RewriteIDs must be true.
+	Loop bool `json:"loop" yaml:"loop"`
+	// RewriteIDs replaces event IDs with unique IDs.
+	RewriteIDs bool `json:"rewriteIds" yaml:"rewriteIds"`
+	// OriginalPace sends events preserving the relative times of the event time attribute instead of using the
+	// sender frequency or profile.
+	OriginalPace bool `json:"originalPace" yaml:"originalPace"`
+	// ParsedEvents are the events read from the file, so that the file is read once per run.
+	ParsedEvents []event.Event `json:"-" yaml:"-"`
+}
+
+type SourceFormat string
+
+const (
+	// CloudEventsSourceFormat is a file with one CloudEvent in structured JSON format per line.
+	CloudEventsSourceFormat SourceFormat = "cloudevents"
+	// VegetaSourceFormat is a file with one vegeta JSON target per line, target bodies must be CloudEvents.
+	VegetaSourceFormat SourceFormat = "vegeta"
+)
+
+type Encoding string
+
+const (
+	// StructuredEncoding sends events in structured content mode using the JSON format.
+	StructuredEncoding Encoding = "structured"
+	// BinaryEncoding sends events in binary content mode.
+	BinaryEncoding Encoding = "binary"
+	// BatchEncoding sends events in batched content mode using the JSON batch format.
+	BatchEncoding Encoding = "batch"
+)
+
+// EventConfig configures the events sent, attributes that aren't specified keep the default values.
+type EventConfig struct {
+	Type            string            `json:"type" yaml:"type"`
+	Source          string            `json:"source" yaml:"source"`
+	Subject         string            `json:"subject" yaml:"subject"`
+	DataSchema      string            `json:"dataschema" yaml:"dataschema"`
+	Extensions      map[string]string `json:"extensions" yaml:"extensions"`
+	DataContentType string            `json:"contentType" yaml:"contentType"`
+
+	Data *DataConfig `json:"data" yaml:"data"`
+}
+
+// DataConfig configures the data of the events sent, only one of Size, MinSize and MaxSize, File or Template can
+// be specified.
+type DataConfig struct {
+	// Size is the size in bytes of random data.
+	Size int `json:"size" yaml:"size"`
+	// MinSize is the minimum size in bytes of random data, the size is uniformly distributed between MinSize and
+	// MaxSize.
+	//
+	// When MinSize is specified, MaxSize must be specified.
+	MinSize int `json:"minSize" yaml:"minSize"`
+	// MaxSize is the maximum size in bytes of random data.
+	MaxSize int `json:"maxSize" yaml:"maxSize"`
+	// File is the path of a file whose content is used as data.
+	File string `json:"file" yaml:"file"`
+	// Template is a text/template executed for each event, the template has access to the event ID as {{.ID}} and
+	// to the time the event is generated as {{.Time}}.
+	Template string `json:"template" yaml:"template"`
+}
+
+// ProfileConfig configures the traffic shape, only one profile can be specified.
+type ProfileConfig struct {
+	Linear *LinearProfileConfig `json:"linear" yaml:"linear"`
+	Steps  []StepProfileConfig  `json:"steps" yaml:"steps"`
+	Sine   *SineProfileConfig   `json:"sine" yaml:"sine"`
+	Burst  *BurstProfileConfig  `json:"burst" yaml:"burst"`
+}
+
+// LinearProfileConfig ramps the rate linearly from From to To events per second over Duration, after Duration
+// the rate stays at To.
+type LinearProfileConfig struct {
+	From     int    `json:"from" yaml:"from"`
+	To       int    `json:"to" yaml:"to"`
+	Duration string `json:"duration" yaml:"duration"`
+
+	ParsedDuration time.Duration `json:"-" yaml:"-"`
+}
+
+// StepProfileConfig sends events at a constant rate of Frequency events per second for Duration, after the last
+// step the rate stays at the last step Frequency.
+type StepProfileConfig struct {
+	Duration  string `json:"duration" yaml:"duration"`
+	Frequency int    `json:"frequency" yaml:"frequency"`
+
+	ParsedDuration time.Duration `json:"-" yaml:"-"`
+}
+
+// SineProfileConfig sends events at a rate oscillating around Mean events per second with the given Amplitude and
+// Period.
+type SineProfileConfig struct {
+	Period    string `json:"period" yaml:"period"`
+	Mean      int    `json:"mean" yaml:"mean"`
+	Amplitude int    `json:"amplitude" yaml:"amplitude"`
+
+	ParsedPeriod time.Duration `json:"-" yaml:"-"`
+}
+
+// BurstProfileConfig sends Size events every Interval.
+type BurstProfileConfig struct {
+	Size     int    `json:"size" yaml:"size"`
+	Interval string `json:"interval" yaml:"interval"`
+
+	ParsedInterval time.Duration `json:"-" yaml:"-"`
 }
 
 type ReceiverConfig struct {
+	// Disabled disables the receiver, it's used to run a sender-only instance in distributed mode.
+	Disabled                  bool   `json:"disabled" yaml:"disabled"`
 	Port                      int    `json:"port" yaml:"port"`
 	Timeout                   string `json:"timeout" yaml:"timeout"`
 	MaxDuplicatesPercentage   *int   `json:"maxDuplicatesPercentage" yaml:"maxDuplicatesPercentage"`
@@ -43,9 +381,100 @@ type ReceiverConfig struct {
 
 	ReceiverFaultConfig *ReceiverFaultConfig `json:"fault" yaml:"fault"`
 
-	ParsedTimeout time.Duration
+	// Record records every received event to a file.
+	Record *RecordConfig `json:"record" yaml:"record"`
+
+	// TLS serves HTTPS instead of HTTP, HTTP/2 is negotiated with senders supporting it.
+	TLS *ReceiverTLSConfig `json:"tls" yaml:"tls"`
+
+	// Auth rejects requests without valid credentials.
+	Auth *ReceiverAuthConfig `json:"auth" yaml:"auth"`
+
+	// Reply responds to received events with reply events, replies must be delivered back to the receiver.
+	Reply *ReplyConfig `json:"reply" yaml:"reply"`
+
+	ParsedTimeout time.Duration `json:"-" yaml:"-"`
+	// ParsedPhaseFaults are the fault configurations of the scenario phases by phase name.
+	ParsedPhaseFaults map[string]*ReceiverFaultConfig `json:"-" yaml:"-"`
+}
+
+// ReplyConfig configures the reply events the receiver responds with.
+//
+// A reply has the ID of the incoming event with the ReplyIDSuffix and the ReplyToExtension set to the ID of the
+// incoming event. Replies are tracked as a second generation of events: every reply must be received on Path, with
+// the same delivery guarantee as the events sent by the sender.
+//
+// Only events received one per request are replied to, since a response holds a single event.
+type ReplyConfig struct {
+	// Fraction is the fraction of events, between 0 and 1, replied to, it defaults to 1.
+	//
+	// Whether an event is replied to depends on its ID, so that redeliveries of an event get the same reply.
+	Fraction *float64 `json:"fraction" yaml:"fraction"`
+	// Type is the type of reply events, it defaults to DefaultReplyType.
+	Type string `json:"type" yaml:"type"`
+	// Source is the source of reply events, it defaults to DefaultReplySource.
+	Source string `json:"source" yaml:"source"`
+	// Path is the receiver path replies must be delivered to, it defaults to DefaultReplyPath.
+	Path string `json:"path" yaml:"path"`
+}
+
+// ReceiverTLSConfig configures the certificate of the receiver and the verification of client certificates.
+type ReceiverTLSConfig struct {
+	// CertFile and KeyFile are the paths of the PEM certificate and key of the receiver.
+	CertFile string `json:"certFile" yaml:"certFile"`
+	KeyFile  string `json:"keyFile" yaml:"keyFile"`
+	// ClientCAFile is the path of a PEM bundle of the CAs verifying client certificates, when it's specified
+	// requests without a valid client certificate are rejected.
+	ClientCAFile string `json:"clientCaFile" yaml:"clientCaFile"`
+}
+
+// ReceiverAuthConfig configures the verification of bearer tokens presented to the receiver, only one of
+// BearerToken and JWT can be specified.
+//
+// Events in rejected requests aren't considered received, they're counted separately in the report.
+type ReceiverAuthConfig struct {
+	// BearerToken is the static token requests must present.
+	BearerToken string `json:"bearerToken" yaml:"bearerToken"`
+	// JWT verifies that requests present a valid JWT.
+	JWT *JWTAuthConfig `json:"jwt" yaml:"jwt"`
+
+	// MaxRejectedEvents is the maximum number of events in rejected requests.
+	//
+	// When it isn't specified, rejected requests are reported but they don't fail the run.
+	MaxRejectedEvents *int `json:"maxRejectedEvents" yaml:"maxRejectedEvents"`
+}
+
+// JWTAuthConfig configures the verification of JWTs, tokens must be signed with one of the keys in JWKSFile using
+// RS256, RS384, RS512, PS256, PS384, PS512, ES256, ES384 or ES512.
+type JWTAuthConfig struct {
+	// JWKSFile is the path of a JSON Web Key Set file.
+	JWKSFile string `json:"jwksFile" yaml:"jwksFile"`
+	// Audience is the audience tokens must have, when it's empty the audience isn't verified.
+	Audience string `json:"audience" yaml:"audience"`
+	// Issuer is the issuer tokens must have, when it's empty the issuer isn't verified.
+	Issuer string `json:"issuer" yaml:"issuer"`
 }
 
+// RecordConfig configures the file events are recorded to, one JSON object per line.
+type RecordConfig struct {
+	// File is the path of the file, it's truncated if it already exists.
+	File string `json:"file" yaml:"file"`
+	// Compression is the compression of the file, it defaults to NoCompression.
+	Compression Compression `json:"compression" yaml:"compression"`
+	// IncludeData includes the event data in the recorded events.
+	IncludeData bool `json:"includeData" yaml:"includeData"`
+	// ParsedRedactedHeaders are the canonical names of the headers carrying credentials configured in
+	// sender.auth.headers, their values aren't recorded.
+	ParsedRedactedHeaders []string `json:"-" yaml:"-"`
+}
+
+type Compression string
+
+const (
+	NoCompression   Compression = "none"
+	GzipCompression Compression = "gzip"
+)
+
 type ReceiverFaultConfig struct {
 	// MinSleepDuration is the minimum duration to sleep before sending the response.
 	//
@@ -53,9 +482,34 @@ type ReceiverFaultConfig struct {
 	MinSleepDuration *time.Duration `json:"minSleepDuration" yaml:"minSleepDuration"`
 	// MaxSleepDuration is the maximum duration to sleep before sending the response.
 	MaxSleepDuration *time.Duration `json:"maxSleepDuration" yaml:"maxSleepDuration"`
+
+	// ErrorProbability is the probability, between 0 and 1, of responding with one of ErrorStatusCodes.
+	//
+	// Events in requests answered with an error aren't considered received.
+	ErrorProbability float64 `json:"errorProbability" yaml:"errorProbability"`
+	// ErrorStatusCodes are the status codes to pick from at random when responding with an error, it defaults to 500.
+	ErrorStatusCodes []int `json:"errorStatusCodes" yaml:"errorStatusCodes"`
+	// RetryAfter is the value of the Retry-After header of error responses, when it isn't specified the header isn't
+	// set.
+	RetryAfter string `json:"retryAfter" yaml:"retryAfter"`
+
+	// DropProbability is the probability, between 0 and 1, of closing the connection without sending a response.
+	//
+	// Events in dropped requests aren't considered received.
+	DropProbability float64 `json:"dropProbability" yaml:"dropProbability"`
+
+	// Paths overrides the fault configuration for requests with the given path, paths must match exactly.
+	Paths map[string]*ReceiverFaultConfig `json:"paths" yaml:"paths"`
+
+	// ParsedRetryAfter is nil when RetryAfter isn't specified.
+	ParsedRetryAfter *time.Duration `json:"-" yaml:"-"`
 }
 
-func FileConfig(r io.Reader) (Config, error) {
+// FileConfig reads and validates a configuration, unknown and duplicate fields are errors reported with their line
+// number.
+//
+// The given overrides are applied in order before the configuration is validated, later overrides take precedence.
+func FileConfig(r io.Reader, overrides ...Override) (Config, error) {
 
 	b, err := ioutil.ReadAll(r)
 	if err != nil {
@@ -63,8 +517,19 @@ func FileConfig(r io.Reader) (Config, error) {
 	}
 
 	config := &Config{}
-	if err := yaml.Unmarshal(b, config); err != nil {
-		return Config{}, fmt.Errorf("failed to unmarshal file content %s: %w", string(b), err)
+	if err := yaml.UnmarshalStrict(b, config); err != nil {
+		return Config{}, fmt.Errorf("failed to unmarshal configuration: %w", err)
+	}
+
+	if len(overrides) > 0 {
+		b, err = applyOverrides(b, overrides)
+		if err != nil {
+			return Config{}, err
+		}
+		config = &Config{}
+		if err := yaml.UnmarshalStrict(b, config); err != nil {
+			return Config{}, fmt.Errorf("failed to unmarshal overridden configuration: %w", err)
+		}
 	}
 
 	return *config, config.validate()
@@ -73,51 +538,634 @@ func FileConfig(r io.Reader) (Config, error) {
 func (c *Config) validate() error {
 	var err error
 
-	c.ParsedDuration, err = time.ParseDuration(c.Duration)
-	if err != nil {
-		return invalidErr("duration", err)
+	if len(c.Scenario) > 0 {
+		if err := c.validateScenario(); err != nil {
+			return err
+		}
+	} else {
+		c.ParsedDuration, err = time.ParseDuration(c.Duration)
+		if err != nil {
+			return invalidErr("duration", err)
+		}
+	}
+
+	if !c.Sender.Disabled && c.Sender.Source != nil {
+		if err := c.Sender.Source.validate(); err != nil {
+			return err
+		}
 	}
 
-	if !c.Sender.Disabled && c.Sender.FrequencyPerSecond <= 0 {
+	if !c.Sender.Disabled && len(c.Scenario) == 0 && !c.Sender.hasRate() {
 		return invalidErr("sender.frequency", errors.New("frequency cannot be less or equal to 0"))
 	}
 
-	if !c.Sender.Disabled && c.Sender.Target == "" {
+	if !c.Sender.Disabled && c.Sender.Profile != nil {
+		if err := c.Sender.Profile.validate("sender.profile"); err != nil {
+			return err
+		}
+	}
+
+	if !c.Sender.Disabled && len(c.Scenario) == 0 && c.Sender.Target == "" && len(c.Sender.Targets) == 0 {
 		return invalidErr("sender.target", errors.New("target cannot be empty"))
 	}
 
+	if !c.Sender.Disabled {
+		if err := c.Sender.validateTargets(); err != nil {
+			return err
+		}
+	}
+
+	if !c.Sender.Disabled && c.Sender.Event != nil {
+		if err := c.Sender.Event.validate("sender.event"); err != nil {
+			return err
+		}
+	}
+
 	if c.Receiver.MaxDuplicatesPercentage != nil && *c.Receiver.MaxDuplicatesPercentage < 0 {
 		return invalidErr("receiver.maxDuplicatesPercentage", errors.New("cannot be negative"))
 	}
 
-	if c.Receiver.ReceiverFaultConfig != nil && c.Receiver.ReceiverFaultConfig.MinSleepDuration != nil {
-		if c.Receiver.ReceiverFaultConfig.MaxSleepDuration == nil {
+	if c.Ordered != nil && c.Ordered.MaxOrderingViolations != nil && *c.Ordered.MaxOrderingViolations < 0 {
+		return invalidErr("ordered.maxOrderingViolations", errors.New("cannot be negative"))
+	}
+
+	if c.Integrity != nil && c.Integrity.MaxViolations != nil && *c.Integrity.MaxViolations < 0 {
+		return invalidErr("integrity.maxViolations", errors.New("cannot be negative"))
+	}
+
+	if !sort.Float64sAreSorted(c.Metrics.HistogramBoundaries) {
+		return invalidErr("metrics.histogramBoundaries", errors.New("boundaries must be sorted in increasing order"))
+	}
+
+	if c.Metrics.Address != "" {
+		if _, _, err := net.SplitHostPort(c.Metrics.Address); err != nil {
+			return invalidErr("metrics.address", err)
+		}
+	}
+
+	if c.Receiver.ReceiverFaultConfig != nil {
+		if err := c.Receiver.ReceiverFaultConfig.validate("receiver.fault"); err != nil {
+			return err
+		}
+	}
+
+	if c.Receiver.Record != nil {
+		if err := c.Receiver.Record.validate("receiver.record"); err != nil {
+			return err
+		}
+	}
+
+	if !c.Sender.Disabled && c.Sender.Record != nil {
+		if err := c.Sender.Record.validate("sender.record"); err != nil {
+			return err
+		}
+	}
+
+	if !c.Receiver.Disabled && c.Receiver.TLS != nil {
+		if _, err := c.Receiver.TLS.tlsConfig(); err != nil {
+			return invalidErr("receiver.tls", err)
+		}
+	}
+
+	if !c.Sender.Disabled && c.Sender.TLS != nil {
+		if _, err := c.Sender.TLS.tlsConfig(); err != nil {
+			return invalidErr("sender.tls", err)
+		}
+	}
+
+	if !c.Receiver.Disabled && c.Receiver.Auth != nil {
+		if err := c.Receiver.Auth.validate("receiver.auth"); err != nil {
+			return err
+		}
+	}
+
+	if !c.Receiver.Disabled && c.Receiver.Reply != nil {
+		if err := c.Receiver.Reply.validate("receiver.reply"); err != nil {
+			return err
+		}
+	}
+
+	if !c.Sender.Disabled && c.Sender.Auth != nil {
+		if err := c.Sender.Auth.validate("sender.auth"); err != nil {
+			return err
+		}
+		if c.Receiver.Record != nil && len(c.Sender.Auth.Headers) > 0 {
+			// The receiver records the headers of the events sent with these credentials.
+			c.Receiver.Record.ParsedRedactedHeaders = make([]string, 0, len(c.Sender.Auth.Headers))
+			for name := range c.Sender.Auth.Headers {
+				c.Receiver.Record.ParsedRedactedHeaders = append(c.Receiver.Record.ParsedRedactedHeaders, http.CanonicalHeaderKey(name))
+			}
+			sort.Strings(c.Receiver.Record.ParsedRedactedHeaders)
+		}
+	}
+
+	if !c.Sender.Disabled && c.Sender.HTTP2 {
+		if err := c.validateHTTP2(); err != nil {
+			return err
+		}
+	}
+
+	if !c.Sender.Disabled && c.Sender.Target != "" {
+		if err := validateTarget("sender.target", c.Sender.Target); err != nil {
+			return err
+		}
+	}
+
+	if c.Sender.Workers == 0 {
+		c.Sender.Workers = vegeta.DefaultWorkers
+	}
+
+	if c.Sender.Disabled && c.Receiver.Disabled {
+		return invalidErr("receiver.disabled", errors.New("sender and receiver cannot be both disabled"))
+	}
+
+	if c.StateFile != "" && c.RunID == "" {
+		return invalidErr("runId", errors.New("runId is required when stateFile is specified, so that states can be reconciled"))
+	}
+
+	if !c.Receiver.Disabled {
+		c.Receiver.ParsedTimeout, err = time.ParseDuration(c.Receiver.Timeout)
+		if err != nil {
+			return invalidErr("receiver.timeout", err)
+		}
+	}
+
+	switch c.Sender.Encoding {
+	case "":
+		c.Sender.Encoding = StructuredEncoding
+	case StructuredEncoding, BinaryEncoding:
+	case BatchEncoding:
+		if !c.Sender.Disabled && c.Sender.BatchSize <= 0 {
+			return invalidErr("sender.batchSize", errors.New("batchSize must be greater than 0 when encoding is batch"))
+		}
+	default:
+		return invalidErr("sender.encoding", fmt.Errorf("unknown encoding %q, supported values are %q, %q and %q",
+			c.Sender.Encoding, StructuredEncoding, BinaryEncoding, BatchEncoding))
+	}
+
+	if c.Report != nil {
+		if c.Report.Format, err = ParseReportFormat(string(c.Report.Format)); err != nil {
+			return invalidErr("report.format", err)
+		}
+	}
+
+	switch c.DeliveryGuarantee {
+	case "":
+		c.DeliveryGuarantee = AtLeastOnce
+	case AtLeastOnce, AtMostOnce, ExactlyOnce:
+	default:
+		return invalidErr("deliveryGuarantee", fmt.Errorf("unknown delivery guarantee %q, supported values are %q, %q and %q",
+			c.DeliveryGuarantee, AtLeastOnce, AtMostOnce, ExactlyOnce))
+	}
+
+	return err
+}
+
+func (c *Config) validateScenario() error {
+	names := sets.NewString()
+	c.ParsedDuration = 0
+	for i := range c.Scenario {
+		p := &c.Scenario[i]
+		field := fmt.Sprintf("scenario[%d]", i)
+
+		if p.Name == "" {
+			p.Name = fmt.Sprintf("phase-%d", i+1)
+		}
+		if names.Has(p.Name) {
+			return invalidErr(field+".name", fmt.Errorf("duplicate phase name %q", p.Name))
+		}
+		names.Insert(p.Name)
+
+		var err error
+		if p.ParsedDuration, err = parseDuration(field+".duration", p.Duration); err != nil {
+			return err
+		}
+		if p.ParsedDuration <= 0 {
+			return invalidErr(field+".duration", errors.New("duration must be greater than 0"))
+		}
+		if p.ParsedPause, err = parseDuration(field+".pause", p.Pause); err != nil {
+			return err
+		}
+		if p.ParsedPause < 0 {
+			return invalidErr(field+".pause", errors.New("cannot be negative"))
+		}
+		c.ParsedDuration += p.ParsedDuration + p.ParsedPause
+
+		if !c.Sender.Disabled {
+			if p.Frequency < 0 {
+				return invalidErr(field+".frequency", errors.New("cannot be negative"))
+			}
+			if p.Frequency == 0 && p.Profile == nil && !c.Sender.hasRate() {
+				return invalidErr(field+".frequency", errors.New("frequency must be specified when sender frequency or profile isn't specified"))
+			}
+			if p.Profile != nil {
+				if err := p.Profile.validate(field + ".profile"); err != nil {
+					return err
+				}
+			}
+			if p.Target == "" && c.Sender.Target == "" && len(c.Sender.Targets) == 0 {
+				return invalidErr(field+".target", errors.New("target must be specified when sender target isn't specified"))
+			}
+			if p.Target != "" {
+				if err := validateTarget(field+".target", p.Target); err != nil {
+					return err
+				}
+			}
+			if p.Event != nil {
+				if err := p.Event.validate(field + ".event"); err != nil {
+					return err
+				}
+			}
+		}
+
+		if p.Fault != nil {
+			if err := p.Fault.validate(field + ".fault"); err != nil {
+				return err
+			}
+			if c.Receiver.ParsedPhaseFaults == nil {
+				c.Receiver.ParsedPhaseFaults = make(map[string]*ReceiverFaultConfig, len(c.Scenario))
+			}
+			c.Receiver.ParsedPhaseFaults[p.Name] = p.Fault
+		}
+	}
+	return nil
+}
+
+func (s *SenderConfig) validateTargets() error {
+	if s.Target != "" && len(s.Targets) > 0 {
+		return invalidErr("sender.targets", errors.New("target and targets cannot be both specified"))
+	}
+	urls := sets.NewString()
+	for i := range s.Targets {
+		t := &s.Targets[i]
+		field := fmt.Sprintf("sender.targets[%d]", i)
+		if err := validateTarget(field+".url", t.URL); err != nil {
+			return err
+		}
+		if urls.Has(t.URL) {
+			return invalidErr(field+".url", fmt.Errorf("duplicate target %q", t.URL))
+		}
+		urls.Insert(t.URL)
+		if t.Weight < 0 {
+			return invalidErr(field+".weight", errors.New("cannot be negative"))
+		}
+		if t.Weight == 0 {
+			t.Weight = 1
+		}
+	}
+	switch s.TargetSelection {
+	case "":
+		if len(s.Targets) > 0 {
+			s.TargetSelection = WeightedTargetSelection
+		}
+	case WeightedTargetSelection, RoundRobinTargetSelection:
+	default:
+		return invalidErr("sender.targetSelection", fmt.Errorf("unknown target selection %q, supported values are %q and %q",
+			s.TargetSelection, WeightedTargetSelection, RoundRobinTargetSelection))
+	}
+	return nil
+}
+
+// validateHTTP2 checks that every target is an HTTPS URL, since HTTP/2 is negotiated with TLS.
+func (c *Config) validateHTTP2() error {
+	targets := make([]string, 0, len(c.Sender.Targets)+len(c.Scenario)+1)
+	if c.Sender.Target != "" {
+		targets = append(targets, c.Sender.Target)
+	}
+	for _, t := range c.Sender.Targets {
+		targets = append(targets, t.URL)
+	}
+	for _, p := range c.Scenario {
+		if p.Target != "" {
+			targets = append(targets, p.Target)
+		}
+	}
+	for _, target := range targets {
+		if u, err := url.Parse(target); err == nil && u.Scheme != "https" {
+			return invalidErr("sender.http2", fmt.Errorf("target %q must be an HTTPS URL when http2 is true", target))
+		}
+	}
+	return nil
+}
+
+// hasRate returns whether the rate of the sender is configured.
+func (s *SenderConfig) hasRate() bool {
+	f := s.Source.file()
+	return s.FrequencyPerSecond > 0 || s.Profile != nil || (f != nil && f.OriginalPace)
+}
+
+func validateTarget(field string, target string) error {
+	u, err := url.Parse(target)
+	if err != nil {
+		return invalidErr(field, err)
+	}
+	if !u.IsAbs() {
+		return invalidErr(field, errors.New("target must be an absolute URL"))
+	}
+	return nil
+}
+
+func (p *ProfileConfig) validate(field string) error {
+	var err error
+	count := 0
+	if p.Linear != nil {
+		count++
+		if p.Linear.From < 0 || p.Linear.To < 0 {
+			return invalidErr(field+".linear", errors.New("from and to cannot be negative"))
+		}
+		if p.Linear.From == 0 && p.Linear.To == 0 {
+			return invalidErr(field+".linear", errors.New("from and to cannot be both 0"))
+		}
+		if p.Linear.ParsedDuration, err = parseDuration(field+".linear.duration", p.Linear.Duration); err != nil {
+			return err
+		}
+		if p.Linear.ParsedDuration <= 0 {
+			return invalidErr(field+".linear.duration", errors.New("duration must be greater than 0"))
+		}
+	}
+	if len(p.Steps) > 0 {
+		count++
+		for i := range p.Steps {
+			s := &p.Steps[i]
+			if s.Frequency < 0 {
+				return invalidErr(fmt.Sprintf("%s.steps[%d].frequency", field, i), errors.New("cannot be negative"))
+			}
+			if s.ParsedDuration, err = parseDuration(fmt.Sprintf("%s.steps[%d].duration", field, i), s.Duration); err != nil {
+				return err
+			}
+			if s.ParsedDuration <= 0 {
+				return invalidErr(fmt.Sprintf("%s.steps[%d].duration", field, i), errors.New("duration must be greater than 0"))
+			}
+		}
+	}
+	if p.Sine != nil {
+		count++
+		if p.Sine.ParsedPeriod, err = parseDuration(field+".sine.period", p.Sine.Period); err != nil {
+			return err
+		}
+		if p.Sine.ParsedPeriod <= 0 {
+			return invalidErr(field+".sine.period", errors.New("period must be greater than 0"))
+		}
+		if p.Sine.Mean <= 0 {
+			return invalidErr(field+".sine.mean", errors.New("mean must be greater than 0"))
+		}
+		if p.Sine.Amplitude < 0 || p.Sine.Amplitude >= p.Sine.Mean {
+			// The rate would drop to 0 at the trough of the wave, which stalls the sender.
+			return invalidErr(field+".sine.amplitude", fmt.Errorf("amplitude must be at least 0 and less than mean (%d)", p.Sine.Mean))
+		}
+	}
+	if p.Burst != nil {
+		count++
+		if p.Burst.Size <= 0 {
+			return invalidErr(field+".burst.size", errors.New("size must be greater than 0"))
+		}
+		if p.Burst.ParsedInterval, err = parseDuration(field+".burst.interval", p.Burst.Interval); err != nil {
+			return err
+		}
+		if p.Burst.ParsedInterval <= 0 {
+			return invalidErr(field+".burst.interval", errors.New("interval must be greater than 0"))
+		}
+	}
+	if count != 1 {
+		return invalidErr(field, fmt.Errorf("exactly one of linear, steps, sine or burst must be specified, got %d", count))
+	}
+	return nil
+}
+
+func (s *SourceConfig) validate() error {
+	if s.File == nil {
+		return invalidErr("sender.source", errors.New("file must be specified"))
+	}
+	switch s.File.Format {
+	case "":
+		s.File.Format = CloudEventsSourceFormat
+	case CloudEventsSourceFormat, VegetaSourceFormat:
+	default:
+		return invalidErr("sender.source.file.format", fmt.Errorf("unknown format %q, supported values are %q and %q",
+			s.File.Format, CloudEventsSourceFormat, VegetaSourceFormat))
+	}
+	if s.File.Loop && !s.File.RewriteIDs {
+		return invalidErr("sender.source.file.rewriteIds", errors.New("rewriteIds must be true when loop is true"))
+	}
+	events, err := readSourceFile(s.File)
+	if err != nil {
+		return invalidErr("sender.source.file", err)
+	}
+	s.File.ParsedEvents = events
+	return nil
+}
+
+func (e *EventConfig) validate(field string) error {
+	for name := range e.Extensions {
+		if !event.IsExtensionNameValid(name) {
+			return invalidErr(field+".extensions", fmt.Errorf("invalid extension name %q", name))
+		}
+	}
+	if e.Source != "" {
+		if _, err := url.Parse(e.Source); err != nil {
+			return invalidErr(field+".source", err)
+		}
+	}
+	if e.DataSchema != "" {
+		if _, err := url.Parse(e.DataSchema); err != nil {
+			return invalidErr(field+".dataschema", err)
+		}
+	}
+	if e.Data == nil {
+		return nil
+	}
+
+	d := e.Data
+	count := 0
+	if d.Size != 0 {
+		count++
+		if d.Size < 0 {
+			return invalidErr(field+".data.size", errors.New("cannot be negative"))
+		}
+	}
+	if d.MinSize != 0 || d.MaxSize != 0 {
+		count++
+		if d.MinSize < 0 || d.MaxSize <= d.MinSize {
+			return invalidErr(field+".data", fmt.Errorf("minSize (%d) must be positive and less than maxSize (%d)", d.MinSize, d.MaxSize))
+		}
+	}
+	if d.File != "" {
+		count++
+		if _, err := os.Stat(d.File); err != nil {
+			return invalidErr(field+".data.file", err)
+		}
+	}
+	if d.Template != "" {
+		count++
+		if _, err := template.New("data").Parse(d.Template); err != nil {
+			return invalidErr(field+".data.template", err)
+		}
+	}
+	if count > 1 {
+		return invalidErr(field+".data", errors.New("only one of size, minSize and maxSize, file or template can be specified"))
+	}
+	return nil
+}
+
+func (f *ReceiverFaultConfig) validate(field string) error {
+	if f.MinSleepDuration != nil {
+		if f.MaxSleepDuration == nil {
 			return invalidErr(
-				"receiver.fault.maxSleepDuration",
+				field+".maxSleepDuration",
 				fmt.Errorf(
 					"maxSleepDuration must be specified when minSleepDuration (%v) is configured",
-					c.Receiver.ReceiverFaultConfig.MinSleepDuration,
+					f.MinSleepDuration,
 				),
 			)
 		}
 	}
+	if f.ErrorProbability < 0 || f.ErrorProbability > 1 {
+		return invalidErr(field+".errorProbability", errors.New("must be between 0 and 1"))
+	}
+	for _, code := range f.ErrorStatusCodes {
+		if code < 400 || code > 599 {
+			return invalidErr(field+".errorStatusCodes", fmt.Errorf("status code %d isn't a 4xx or 5xx status code", code))
+		}
+	}
+	if f.RetryAfter != "" {
+		retryAfter, err := parseDuration(field+".retryAfter", f.RetryAfter)
+		if err != nil {
+			return err
+		}
+		if retryAfter < 0 {
+			return invalidErr(field+".retryAfter", errors.New("cannot be negative"))
+		}
+		f.ParsedRetryAfter = &retryAfter
+	}
+	if f.DropProbability < 0 || f.DropProbability > 1 {
+		return invalidErr(field+".dropProbability", errors.New("must be between 0 and 1"))
+	}
+	if f.ErrorProbability+f.DropProbability > 1 {
+		return invalidErr(field, errors.New("the sum of errorProbability and dropProbability cannot be greater than 1"))
+	}
+	for path, p := range f.Paths {
+		if p == nil {
+			continue
+		}
+		if len(p.Paths) > 0 {
+			return invalidErr(fmt.Sprintf("%s.paths[%s].paths", field, path), errors.New("nested paths aren't supported"))
+		}
+		if err := p.validate(fmt.Sprintf("%s.paths[%s]", field, path)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	if u, err := url.Parse(c.Sender.Target); !c.Sender.Disabled && err != nil {
-		return invalidErr("sender.target", err)
-	} else if !c.Sender.Disabled && !u.IsAbs() {
-		return invalidErr("sender.target", errors.New("target must be an absolute URL"))
+func (a *SenderAuthConfig) validate(field string) error {
+	count := 0
+	if a.BearerToken != "" {
+		count++
 	}
+	if a.TokenFile != "" {
+		count++
+		if _, err := readToken(a.TokenFile); err != nil {
+			return invalidErr(field+".tokenFile", err)
+		}
+		var err error
+		if a.ParsedTokenRefreshInterval, err = parseDuration(field+".tokenRefreshInterval", a.TokenRefreshInterval); err != nil {
+			return err
+		}
+		if a.ParsedTokenRefreshInterval < 0 {
+			return invalidErr(field+".tokenRefreshInterval", errors.New("cannot be negative"))
+		}
+		if a.ParsedTokenRefreshInterval == 0 {
+			a.ParsedTokenRefreshInterval = DefaultTokenRefreshInterval
+		}
+	}
+	if a.Basic != nil {
+		count++
+		if a.Basic.Username == "" {
+			return invalidErr(field+".basic.username", errors.New("username cannot be empty"))
+		}
+	}
+	if count > 1 {
+		return invalidErr(field, errors.New("only one of bearerToken, tokenFile or basic can be specified"))
+	}
+	for name := range a.Headers {
+		if name == "" {
+			return invalidErr(field+".headers", errors.New("header name cannot be empty"))
+		}
+		if count > 0 && http.CanonicalHeaderKey(name) == "Authorization" {
+			return invalidErr(field+".headers", errors.New("the Authorization header cannot be specified with bearerToken, tokenFile or basic"))
+		}
+	}
+	return nil
+}
 
-	if c.Sender.Workers == 0 {
-		c.Sender.Workers = vegeta.DefaultWorkers
+func (a *ReceiverAuthConfig) validate(field string) error {
+	if (a.BearerToken == "") == (a.JWT == nil) {
+		return invalidErr(field, errors.New("exactly one of bearerToken or jwt must be specified"))
 	}
+	if a.JWT != nil {
+		if _, err := loadJWKS(a.JWT.JWKSFile); err != nil {
+			return invalidErr(field+".jwt.jwksFile", err)
+		}
+	}
+	if a.MaxRejectedEvents != nil && *a.MaxRejectedEvents < 0 {
+		return invalidErr(field+".maxRejectedEvents", errors.New("cannot be negative"))
+	}
+	return nil
+}
 
-	c.Receiver.ParsedTimeout, err = time.ParseDuration(c.Receiver.Timeout)
-	if err != nil {
-		return invalidErr("receiver.timeout", err)
+func (r *ReplyConfig) validate(field string) error {
+	if r.Fraction == nil {
+		fraction := 1.0
+		r.Fraction = &fraction
+	}
+	if *r.Fraction < 0 || *r.Fraction > 1 {
+		return invalidErr(field+".fraction", errors.New("must be between 0 and 1"))
+	}
+	if r.Type == "" {
+		r.Type = DefaultReplyType
+	}
+	if r.Source == "" {
+		r.Source = DefaultReplySource
+	}
+	if r.Path == "" {
+		r.Path = DefaultReplyPath
+	}
+	if !strings.HasPrefix(r.Path, "/") {
+		return invalidErr(field+".path", errors.New("must start with /"))
 	}
+	return nil
+}
 
-	return err
+func (r *RecordConfig) validate(field string) error {
+	if r.File == "" {
+		return invalidErr(field+".file", errors.New("file cannot be empty"))
+	}
+	if info, err := os.Stat(filepath.Dir(r.File)); err != nil {
+		return invalidErr(field+".file", err)
+	} else if !info.IsDir() {
+		return invalidErr(field+".file", fmt.Errorf("%s isn't a directory", filepath.Dir(r.File)))
+	}
+	switch r.Compression {
+	case "":
+		r.Compression = NoCompression
+	case NoCompression, GzipCompression:
+	default:
+		return invalidErr(field+".compression", fmt.Errorf("unknown compression %q, supported values are %q and %q",
+			r.Compression, NoCompression, GzipCompression))
+	}
+	return nil
+}
+
+// parseDuration parses the duration of the given field, an empty duration is 0.
+func parseDuration(field string, duration string) (time.Duration, error) {
+	if duration == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(duration)
+	if err != nil {
+		return 0, invalidErr(field, err)
+	}
+	return d, nil
 }
 
 func invalidErr(field string, err error) error {
@@ -2,14 +2,17 @@ package sacura
 
 import (
 	"io"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
 )
 
 func TestFileConfig(t *testing.T) {
+	half := 0.5
 
 	tests := []struct {
 		name    string
@@ -29,7 +32,6 @@ receiver:
   port: 8080
   timeout: 1m
 duration: 1m
-timeout: 1m
 `),
 			want: Config{
 				Sender: SenderConfig{
@@ -37,14 +39,16 @@ timeout: 1m
 					FrequencyPerSecond: 1000,
 					Workers:            100,
 					KeepAlive:          true,
+					Encoding:           StructuredEncoding,
 				},
 				Receiver: ReceiverConfig{
 					Port:          8080,
 					Timeout:       "1m",
 					ParsedTimeout: time.Minute,
 				},
-				Duration:       "1m",
-				ParsedDuration: time.Minute,
+				Duration:          "1m",
+				ParsedDuration:    time.Minute,
+				DeliveryGuarantee: AtLeastOnce,
 			},
 			wantErr: false,
 		},
@@ -60,7 +64,6 @@ receiver:
   port: 8080
   timeout: 1m
 duration: 1m
-timeout: 1m
 `),
 			want: Config{
 				Sender: SenderConfig{
@@ -91,7 +94,6 @@ receiver:
   port: 8080
   timeout: 1m
 duration: 1m
-timeout: 1m
 `),
 			want: Config{
 				Sender: SenderConfig{
@@ -122,7 +124,6 @@ receiver:
   port: 8080
   timeout: 1m
 duration: 1H
-timeout: 1m
 `),
 			want: Config{
 				Sender: SenderConfig{
@@ -171,6 +172,606 @@ duration: 1h
 			},
 			wantErr: true,
 		},
+		{
+			name: "exactly once",
+			r: strings.NewReader(`
+sender:
+  target: http://localhost:8080
+  frequency: 1000
+  workers: 100
+  keepAlive: true
+receiver:
+  port: 8080
+  timeout: 1m
+duration: 1m
+deliveryGuarantee: exactlyOnce
+`),
+			want: Config{
+				Sender: SenderConfig{
+					Target:             "http://localhost:8080",
+					FrequencyPerSecond: 1000,
+					Workers:            100,
+					KeepAlive:          true,
+					Encoding:           StructuredEncoding,
+				},
+				Receiver: ReceiverConfig{
+					Port:          8080,
+					Timeout:       "1m",
+					ParsedTimeout: time.Minute,
+				},
+				Duration:          "1m",
+				ParsedDuration:    time.Minute,
+				DeliveryGuarantee: ExactlyOnce,
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid delivery guarantee",
+			r: strings.NewReader(`
+sender:
+  target: http://localhost:8080
+  frequency: 1000
+  workers: 100
+  keepAlive: true
+receiver:
+  port: 8080
+  timeout: 1m
+duration: 1m
+deliveryGuarantee: once
+`),
+			want: Config{
+				Sender: SenderConfig{
+					Target:             "http://localhost:8080",
+					FrequencyPerSecond: 1000,
+					Workers:            100,
+					KeepAlive:          true,
+					Encoding:           StructuredEncoding,
+				},
+				Receiver: ReceiverConfig{
+					Port:          8080,
+					Timeout:       "1m",
+					ParsedTimeout: time.Minute,
+				},
+				Duration:          "1m",
+				ParsedDuration:    time.Minute,
+				DeliveryGuarantee: "once",
+			},
+			wantErr: true,
+		},
+		{
+			name: "steps profile",
+			r: strings.NewReader(`
+sender:
+  target: http://localhost:8080
+  workers: 100
+  profile:
+    steps:
+    - duration: 30s
+      frequency: 100
+    - duration: 30s
+      frequency: 1000
+receiver:
+  port: 8080
+  timeout: 1m
+duration: 1m
+`),
+			want: Config{
+				Sender: SenderConfig{
+					Target:  "http://localhost:8080",
+					Workers: 100,
+					Profile: &ProfileConfig{
+						Steps: []StepProfileConfig{
+							{Duration: "30s", Frequency: 100, ParsedDuration: 30 * time.Second},
+							{Duration: "30s", Frequency: 1000, ParsedDuration: 30 * time.Second},
+						},
+					},
+					Encoding: StructuredEncoding,
+				},
+				Receiver: ReceiverConfig{
+					Port:          8080,
+					Timeout:       "1m",
+					ParsedTimeout: time.Minute,
+				},
+				Duration:          "1m",
+				ParsedDuration:    time.Minute,
+				DeliveryGuarantee: AtLeastOnce,
+			},
+			wantErr: false,
+		},
+		{
+			name: "multiple profiles",
+			r: strings.NewReader(`
+sender:
+  target: http://localhost:8080
+  workers: 100
+  profile:
+    burst:
+      size: 100
+      interval: 10s
+    sine:
+      period: 1m
+      mean: 100
+      amplitude: 10
+receiver:
+  port: 8080
+  timeout: 1m
+duration: 1m
+`),
+			want: Config{
+				Sender: SenderConfig{
+					Target:  "http://localhost:8080",
+					Workers: 100,
+					Profile: &ProfileConfig{
+						Sine:  &SineProfileConfig{Period: "1m", Mean: 100, Amplitude: 10, ParsedPeriod: time.Minute},
+						Burst: &BurstProfileConfig{Size: 100, Interval: "10s", ParsedInterval: 10 * time.Second},
+					},
+				},
+				Receiver: ReceiverConfig{
+					Port:    8080,
+					Timeout: "1m",
+				},
+				Duration:       "1m",
+				ParsedDuration: time.Minute,
+			},
+			wantErr: true,
+		},
+		{
+			name: "sine amplitude equal to mean",
+			r: strings.NewReader(`
+sender:
+  target: http://localhost:8080
+  workers: 100
+  profile:
+    sine:
+      period: 1m
+      mean: 100
+      amplitude: 100
+receiver:
+  port: 8080
+  timeout: 1m
+duration: 1m
+`),
+			want: Config{
+				Sender: SenderConfig{
+					Target:  "http://localhost:8080",
+					Workers: 100,
+					Profile: &ProfileConfig{
+						Sine: &SineProfileConfig{Period: "1m", Mean: 100, Amplitude: 100, ParsedPeriod: time.Minute},
+					},
+				},
+				Receiver: ReceiverConfig{
+					Port:    8080,
+					Timeout: "1m",
+				},
+				Duration:       "1m",
+				ParsedDuration: time.Minute,
+			},
+			wantErr: true,
+		},
+		{
+			name: "metrics",
+			r: strings.NewReader(`
+sender:
+  target: http://localhost:8080
+  frequency: 1000
+receiver:
+  port: 8080
+  timeout: 1m
+duration: 1m
+metrics:
+  address: 127.0.0.1:0
+  histogramBoundaries: [1, 10, 100]
+`),
+			want: Config{
+				Sender: SenderConfig{
+					Target:             "http://localhost:8080",
+					FrequencyPerSecond: 1000,
+					Workers:            vegeta.DefaultWorkers,
+					Encoding:           StructuredEncoding,
+				},
+				Receiver: ReceiverConfig{
+					Port:          8080,
+					Timeout:       "1m",
+					ParsedTimeout: time.Minute,
+				},
+				Duration:          "1m",
+				ParsedDuration:    time.Minute,
+				DeliveryGuarantee: AtLeastOnce,
+				Metrics: MetricsConfig{
+					Address:             "127.0.0.1:0",
+					HistogramBoundaries: []float64{1, 10, 100},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "unsorted histogram boundaries",
+			r: strings.NewReader(`
+sender:
+  target: http://localhost:8080
+  frequency: 1000
+receiver:
+  port: 8080
+  timeout: 1m
+duration: 1m
+metrics:
+  histogramBoundaries: [10, 1]
+`),
+			want: Config{
+				Sender: SenderConfig{
+					Target:             "http://localhost:8080",
+					FrequencyPerSecond: 1000,
+				},
+				Receiver: ReceiverConfig{
+					Port:    8080,
+					Timeout: "1m",
+				},
+				Duration:       "1m",
+				ParsedDuration: time.Minute,
+				Metrics: MetricsConfig{
+					HistogramBoundaries: []float64{10, 1},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "source loop without rewriteIds",
+			r: strings.NewReader(`
+sender:
+  target: http://localhost:8080
+  source:
+    file:
+      path: events.jsonl
+      loop: true
+      originalPace: true
+receiver:
+  port: 8080
+  timeout: 1m
+duration: 1m
+`),
+			want: Config{
+				Sender: SenderConfig{
+					Target: "http://localhost:8080",
+					Source: &SourceConfig{
+						File: &FileSourceConfig{
+							Path:         "events.jsonl",
+							Format:       CloudEventsSourceFormat,
+							Loop:         true,
+							OriginalPace: true,
+						},
+					},
+				},
+				Receiver: ReceiverConfig{
+					Port:    8080,
+					Timeout: "1m",
+				},
+				Duration:       "1m",
+				ParsedDuration: time.Minute,
+			},
+			wantErr: true,
+		},
+		{
+			name: "scenario",
+			r: strings.NewReader(`
+sender:
+  target: http://localhost:8080
+  frequency: 100
+receiver:
+  port: 8080
+  timeout: 1m
+scenario:
+- name: warm-up
+  duration: 10s
+  frequency: 10
+  pause: 5s
+- duration: 1m
+  target: http://localhost:8081
+  fault:
+    errorProbability: 0.5
+`),
+			want: Config{
+				Sender: SenderConfig{
+					Target:             "http://localhost:8080",
+					FrequencyPerSecond: 100,
+					Workers:            vegeta.DefaultWorkers,
+					Encoding:           StructuredEncoding,
+				},
+				Receiver: ReceiverConfig{
+					Port:          8080,
+					Timeout:       "1m",
+					ParsedTimeout: time.Minute,
+					ParsedPhaseFaults: map[string]*ReceiverFaultConfig{
+						"phase-2": {ErrorProbability: 0.5},
+					},
+				},
+				Scenario: []PhaseConfig{
+					{Name: "warm-up", Duration: "10s", Frequency: 10, Pause: "5s", ParsedDuration: 10 * time.Second, ParsedPause: 5 * time.Second},
+					{Name: "phase-2", Duration: "1m", ParsedDuration: time.Minute, Target: "http://localhost:8081", Fault: &ReceiverFaultConfig{ErrorProbability: 0.5}},
+				},
+				ParsedDuration:    75 * time.Second,
+				DeliveryGuarantee: AtLeastOnce,
+			},
+			wantErr: false,
+		},
+		{
+			name: "scenario with duplicate phase names",
+			r: strings.NewReader(`
+sender:
+  target: http://localhost:8080
+  frequency: 100
+receiver:
+  port: 8080
+  timeout: 1m
+scenario:
+- name: steady
+  duration: 10s
+- name: steady
+  duration: 10s
+`),
+			want: Config{
+				Sender: SenderConfig{
+					Target:             "http://localhost:8080",
+					FrequencyPerSecond: 100,
+				},
+				Receiver: ReceiverConfig{
+					Port:    8080,
+					Timeout: "1m",
+				},
+				Scenario: []PhaseConfig{
+					{Name: "steady", Duration: "10s", ParsedDuration: 10 * time.Second},
+					{Name: "steady", Duration: "10s"},
+				},
+				ParsedDuration: 10 * time.Second,
+			},
+			wantErr: true,
+		},
+		{
+			name: "multiple targets",
+			r: strings.NewReader(`
+sender:
+  targets:
+  - url: http://localhost:8080
+  - url: http://localhost:8081
+    weight: 3
+  frequency: 100
+receiver:
+  port: 8080
+  timeout: 1m
+duration: 1m
+`),
+			want: Config{
+				Sender: SenderConfig{
+					Targets: []TargetConfig{
+						{URL: "http://localhost:8080", Weight: 1},
+						{URL: "http://localhost:8081", Weight: 3},
+					},
+					TargetSelection:    WeightedTargetSelection,
+					FrequencyPerSecond: 100,
+					Workers:            vegeta.DefaultWorkers,
+					Encoding:           StructuredEncoding,
+				},
+				Receiver: ReceiverConfig{
+					Port:          8080,
+					Timeout:       "1m",
+					ParsedTimeout: time.Minute,
+				},
+				Duration:          "1m",
+				ParsedDuration:    time.Minute,
+				DeliveryGuarantee: AtLeastOnce,
+			},
+			wantErr: false,
+		},
+		{
+			name: "target and targets",
+			r: strings.NewReader(`
+sender:
+  target: http://localhost:8080
+  targets:
+  - url: http://localhost:8081
+  frequency: 100
+receiver:
+  port: 8080
+  timeout: 1m
+duration: 1m
+`),
+			want: Config{
+				Sender: SenderConfig{
+					Target:             "http://localhost:8080",
+					Targets:            []TargetConfig{{URL: "http://localhost:8081"}},
+					FrequencyPerSecond: 100,
+				},
+				Receiver: ReceiverConfig{
+					Port:    8080,
+					Timeout: "1m",
+				},
+				Duration:       "1m",
+				ParsedDuration: time.Minute,
+			},
+			wantErr: true,
+		},
+		{
+			name: "http2 with http target",
+			r: strings.NewReader(`
+sender:
+  target: http://localhost:8080
+  frequency: 100
+  http2: true
+receiver:
+  port: 8080
+  timeout: 1m
+duration: 1m
+`),
+			want: Config{
+				Sender: SenderConfig{
+					Target:             "http://localhost:8080",
+					FrequencyPerSecond: 100,
+					HTTP2:              true,
+				},
+				Receiver: ReceiverConfig{
+					Port:    8080,
+					Timeout: "1m",
+				},
+				Duration:       "1m",
+				ParsedDuration: time.Minute,
+			},
+			wantErr: true,
+		},
+		{
+			name: "multiple auth methods",
+			r: strings.NewReader(`
+sender:
+  target: http://localhost:8080
+  frequency: 100
+  auth:
+    bearerToken: token
+    basic:
+      username: user
+receiver:
+  port: 8080
+  timeout: 1m
+duration: 1m
+`),
+			want: Config{
+				Sender: SenderConfig{
+					Target:             "http://localhost:8080",
+					FrequencyPerSecond: 100,
+					Auth: &SenderAuthConfig{
+						BearerToken: "token",
+						Basic:       &BasicAuthConfig{Username: "user"},
+					},
+				},
+				Receiver: ReceiverConfig{
+					Port:    8080,
+					Timeout: "1m",
+				},
+				Duration:       "1m",
+				ParsedDuration: time.Minute,
+			},
+			wantErr: true,
+		},
+		{
+			name: "receiver reply defaults",
+			r: strings.NewReader(`
+sender:
+  target: http://localhost:8080
+  frequency: 100
+receiver:
+  port: 8080
+  timeout: 1m
+  reply:
+    fraction: 0.5
+duration: 1m
+`),
+			want: Config{
+				Sender: SenderConfig{
+					Target:             "http://localhost:8080",
+					FrequencyPerSecond: 100,
+					Workers:            10,
+					Encoding:           StructuredEncoding,
+				},
+				Receiver: ReceiverConfig{
+					Port:          8080,
+					Timeout:       "1m",
+					ParsedTimeout: time.Minute,
+					Reply: &ReplyConfig{
+						Fraction: &half,
+						Type:     DefaultReplyType,
+						Source:   DefaultReplySource,
+						Path:     DefaultReplyPath,
+					},
+				},
+				Duration:          "1m",
+				ParsedDuration:    time.Minute,
+				DeliveryGuarantee: AtLeastOnce,
+			},
+		},
+		{
+			name: "receiver auth with bearer token and jwt",
+			r: strings.NewReader(`
+sender:
+  target: http://localhost:8080
+  frequency: 100
+receiver:
+  port: 8080
+  timeout: 1m
+  auth:
+    bearerToken: token
+    jwt:
+      jwksFile: /tmp/jwks.json
+duration: 1m
+`),
+			want: Config{
+				Sender: SenderConfig{
+					Target:             "http://localhost:8080",
+					FrequencyPerSecond: 100,
+				},
+				Receiver: ReceiverConfig{
+					Port:    8080,
+					Timeout: "1m",
+					Auth: &ReceiverAuthConfig{
+						BearerToken: "token",
+						JWT:         &JWTAuthConfig{JWKSFile: "/tmp/jwks.json"},
+					},
+				},
+				Duration:       "1m",
+				ParsedDuration: time.Minute,
+			},
+			wantErr: true,
+		},
+		{
+			name: "record file in a missing directory",
+			r: strings.NewReader(`
+sender:
+  target: http://localhost:8080
+  frequency: 100
+receiver:
+  port: 8080
+  timeout: 1m
+  record:
+    file: /missing/events.jsonl
+duration: 1m
+`),
+			want: Config{
+				Sender: SenderConfig{
+					Target:             "http://localhost:8080",
+					FrequencyPerSecond: 100,
+				},
+				Receiver: ReceiverConfig{
+					Port:    8080,
+					Timeout: "1m",
+					Record:  &RecordConfig{File: "/missing/events.jsonl"},
+				},
+				Duration:       "1m",
+				ParsedDuration: time.Minute,
+			},
+			wantErr: true,
+		},
+		{
+			name: "state file without run ID",
+			r: strings.NewReader(`
+sender:
+  disabled: true
+receiver:
+  port: 8080
+  timeout: 1m
+duration: 1m
+stateFile: /tmp/state.json
+`),
+			want: Config{
+				Sender: SenderConfig{
+					Disabled: true,
+					Workers:  vegeta.DefaultWorkers,
+				},
+				Receiver: ReceiverConfig{
+					Port:    8080,
+					Timeout: "1m",
+				},
+				Duration:       "1m",
+				ParsedDuration: time.Minute,
+				StateFile:      "/tmp/state.json",
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -187,3 +788,63 @@ duration: 1h
 		})
 	}
 }
+
+func TestFileConfigUnknownField(t *testing.T) {
+	_, err := FileConfig(strings.NewReader(`
+sender:
+  target: http://localhost:8080
+  frequency: 100
+receiver:
+  port: 8080
+  timeout: 1m
+  maxDuplicatePercentage: 0
+duration: 1m
+`))
+	if err == nil {
+		t.Fatal("want error for unknown field, got nil")
+	}
+	if !strings.Contains(err.Error(), "line 8: field maxDuplicatePercentage not found") {
+		t.Errorf("want unknown field with its line number, got %v", err)
+	}
+}
+
+func TestFileConfigParsedFields(t *testing.T) {
+	_, err := FileConfig(strings.NewReader(`
+sender:
+  target: http://localhost:8080
+  frequency: 100
+receiver:
+  port: 8080
+  timeout: 1m
+  parsedtimeout: 1h
+duration: 1m
+`))
+	if err == nil || !strings.Contains(err.Error(), "field parsedtimeout not found") {
+		t.Errorf("want parsed fields not to be part of the configuration, got %v", err)
+	}
+}
+
+func TestFileConfigRecordRedactedHeaders(t *testing.T) {
+	dir := t.TempDir()
+	config, err := FileConfig(strings.NewReader(`
+sender:
+  target: http://localhost:8080
+  frequency: 100
+  auth:
+    headers:
+      x-api-key: key
+      X-Tenant-Secret: secret
+receiver:
+  port: 8080
+  timeout: 1m
+  record:
+    file: ` + filepath.Join(dir, "received.jsonl") + `
+duration: 1m
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"X-Api-Key", "X-Tenant-Secret"}, config.Receiver.Record.ParsedRedactedHeaders); diff != "" {
+		t.Errorf("(-want, +got) %s", diff)
+	}
+}
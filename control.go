@@ -0,0 +1,402 @@
+package sacura
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RunControl controls a run while it's in progress.
+type RunControl struct {
+	config Config
+	// logger prefixes messages with the run ID.
+	logger *log.Logger
+
+	senderCtx  context.Context
+	stopSender context.CancelFunc
+	timeout    *extendableTimeout
+	done       chan struct{}
+
+	lock   sync.Mutex
+	sm     *StateManager
+	report *Report
+	err    error
+}
+
+// NewRunControl creates a RunControl for a run with the given configuration.
+//
+// When the run ID isn't specified and the sender is enabled, a random run ID is generated.
+func NewRunControl(config Config) *RunControl {
+	if config.RunID == "" && !config.Sender.Disabled {
+		config.RunID = uuid.New().String()
+	}
+
+	logger := log.Default()
+	if config.RunID != "" {
+		logger = log.New(log.Writer(), fmt.Sprintf("%s[%s] ", log.Prefix(), config.RunID), log.Flags())
+	}
+
+	senderCtx, stopSender := context.WithCancel(context.Background())
+	return &RunControl{
+		config:     config,
+		logger:     logger,
+		senderCtx:  senderCtx,
+		stopSender: stopSender,
+		timeout:    newExtendableTimeout(config.Receiver.ParsedTimeout),
+		done:       make(chan struct{}),
+	}
+}
+
+// RunID returns the ID of the run.
+func (c *RunControl) RunID() string {
+	return c.config.RunID
+}
+
+// StopSender stops the sender, the receiver keeps receiving events until the receiver timeout expires.
+//
+// When the sender is disabled, it stops the receiver as a term signal would do.
+func (c *RunControl) StopSender() {
+	c.stopSender()
+}
+
+// ExtendTimeout extends the receiver timeout by d.
+func (c *RunControl) ExtendTimeout(d time.Duration) {
+	c.timeout.extend(d)
+}
+
+// Progress returns the progress of the run, it returns false when the run hasn't started tracking events yet.
+func (c *RunControl) Progress() (Progress, bool) {
+	c.lock.Lock()
+	sm := c.sm
+	c.lock.Unlock()
+
+	if sm == nil {
+		return Progress{}, false
+	}
+	return sm.Progress(), true
+}
+
+// Done returns a channel that's closed when the run is finished.
+func (c *RunControl) Done() <-chan struct{} {
+	return c.done
+}
+
+// Report returns the report of the run, it returns false when the run isn't finished or when it failed before
+// generating the report.
+func (c *RunControl) Report() (Report, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.report == nil {
+		return Report{}, false
+	}
+	return *c.report, true
+}
+
+// Err returns the error returned by the run, it's nil while the run is in progress.
+func (c *RunControl) Err() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.err
+}
+
+func (c *RunControl) setStateManager(sm *StateManager) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.sm = sm
+}
+
+func (c *RunControl) setReport(report Report) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.report = &report
+}
+
+func (c *RunControl) finish(err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.err = err
+	c.stopSender()
+	close(c.done)
+}
+
+// extendableTimeout is a timeout that can be extended before or while it's running.
+type extendableTimeout struct {
+	lock     sync.Mutex
+	timeout  time.Duration
+	extended chan struct{}
+}
+
+func newExtendableTimeout(timeout time.Duration) *extendableTimeout {
+	return &extendableTimeout{
+		timeout:  timeout,
+		extended: make(chan struct{}, 1),
+	}
+}
+
+func (t *extendableTimeout) extend(d time.Duration) {
+	t.lock.Lock()
+	t.timeout += d
+	t.lock.Unlock()
+
+	select {
+	case t.extended <- struct{}{}:
+	default:
+	}
+}
+
+// wait waits for the timeout to expire, starting from now.
+func (t *extendableTimeout) wait() {
+	start := time.Now()
+	for {
+		t.lock.Lock()
+		remaining := t.timeout - time.Since(start)
+		t.lock.Unlock()
+
+		if remaining <= 0 {
+			return
+		}
+
+		timer := time.NewTimer(remaining)
+		select {
+		case <-timer.C:
+		case <-t.extended:
+			timer.Stop()
+		}
+	}
+}
+
+// ControlServer is an HTTP server starting, inspecting and stopping runs.
+//
+// Only one run at a time is supported, the endpoints are:
+//   - POST /runs starts a run with the configuration in the request body (YAML or JSON).
+//   - GET /runs/current returns the state of the current or last run.
+//   - POST /runs/current/stop stops the sender of the current run.
+//   - POST /runs/current/timeout?extend=<duration> extends the receiver timeout of the current run.
+//   - GET /runs/current/report?format=<format> returns the report of the last finished run.
+//
+// When a token is configured, every request must carry it as bearer token.
+type ControlServer struct {
+	ctx      context.Context
+	mux      *http.ServeMux
+	verifier *tokenVerifier
+
+	lock    sync.Mutex
+	current *RunControl
+}
+
+// NewControlServer creates a ControlServer, runs are stopped when ctx is done.
+//
+// Requests must carry the given bearer token, an empty token disables authentication.
+func NewControlServer(ctx context.Context, token string) *ControlServer {
+	s := &ControlServer{ctx: ctx, mux: http.NewServeMux()}
+	if token != "" {
+		s.verifier = &tokenVerifier{config: &ReceiverAuthConfig{BearerToken: token}}
+	}
+	s.mux.HandleFunc("/runs", s.handleStart)
+	s.mux.HandleFunc("/runs/current", s.handleState)
+	s.mux.HandleFunc("/runs/current/stop", s.handleStop)
+	s.mux.HandleFunc("/runs/current/timeout", s.handleTimeout)
+	s.mux.HandleFunc("/runs/current/report", s.handleReport)
+	return s
+}
+
+// StartControlServer starts a ControlServer listening on the given address until ctx is done, requests must carry
+// the given bearer token unless it's empty.
+func StartControlServer(ctx context.Context, address string, token string) error {
+	s := http.Server{
+		Addr:    address,
+		Handler: NewControlServer(ctx, token),
+	}
+
+	// The control server isn't part of a run, so it logs without a run ID.
+	logger := log.Default()
+
+	errChan := make(chan error, 1)
+	go func() {
+		logger.Println("Control server listening on", address)
+		errChan <- s.ListenAndServe()
+	}()
+
+	select {
+	case <-ctx.Done():
+		return s.Close()
+	case err := <-errChan:
+		return err
+	}
+}
+
+func (s *ControlServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if reason := s.verifier.verify(r); reason != "" {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, fmt.Sprintf("unauthorized: %s", reason), http.StatusUnauthorized)
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// RunState is the state of a run returned by the control server.
+type RunState struct {
+	RunID    string    `json:"runId,omitempty"`
+	Finished bool      `json:"finished"`
+	Progress *Progress `json:"progress,omitempty"`
+	Verdict  *Verdict  `json:"verdict,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+func (s *ControlServer) handleStart(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	config, err := FileConfig(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.current != nil {
+		select {
+		case <-s.current.Done():
+		default:
+			http.Error(w, fmt.Sprintf("run %s is in progress", s.current.RunID()), http.StatusConflict)
+			return
+		}
+	}
+
+	rc := NewRunControl(config)
+	s.current = rc
+	go func() {
+		// run recovers from panics, so that a failed run doesn't stop the server.
+		if err := run(s.ctx, rc); err != nil {
+			rc.logger.Println("run failed", err)
+		}
+	}()
+
+	writeJSON(rc.logger, w, http.StatusAccepted, RunState{RunID: rc.RunID()})
+}
+
+func (s *ControlServer) handleState(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rc, ok := s.currentRun(w)
+	if !ok {
+		return
+	}
+
+	state := RunState{RunID: rc.RunID()}
+	if p, ok := rc.Progress(); ok {
+		state.Progress = &p
+	}
+	select {
+	case <-rc.Done():
+		state.Finished = true
+		if report, ok := rc.Report(); ok {
+			state.Verdict = &report.Verdict
+		}
+		if err := rc.Err(); err != nil {
+			state.Error = err.Error()
+		}
+	default:
+	}
+	writeJSON(rc.logger, w, http.StatusOK, state)
+}
+
+func (s *ControlServer) handleStop(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rc, ok := s.currentRun(w)
+	if !ok {
+		return
+	}
+
+	rc.StopSender()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *ControlServer) handleTimeout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	d, err := time.ParseDuration(r.URL.Query().Get("extend"))
+	if err != nil || d <= 0 {
+		http.Error(w, fmt.Sprintf("invalid extend query parameter %q, it must be a positive duration", r.URL.Query().Get("extend")), http.StatusBadRequest)
+		return
+	}
+	rc, ok := s.currentRun(w)
+	if !ok {
+		return
+	}
+
+	rc.ExtendTimeout(d)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *ControlServer) handleReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	format, err := ParseReportFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rc, ok := s.currentRun(w)
+	if !ok {
+		return
+	}
+
+	select {
+	case <-rc.Done():
+	default:
+		http.Error(w, fmt.Sprintf("run %s is in progress", rc.RunID()), http.StatusConflict)
+		return
+	}
+	report, ok := rc.Report()
+	if !ok {
+		http.Error(w, fmt.Sprintf("run %s failed without a report: %v", rc.RunID(), rc.Err()), http.StatusInternalServerError)
+		return
+	}
+	if err := WriteReport(w, format, report); err != nil {
+		rc.logger.Println("failed to write report", err)
+	}
+}
+
+func (s *ControlServer) currentRun(w http.ResponseWriter) (*RunControl, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.current == nil {
+		http.Error(w, "no run has been started", http.StatusNotFound)
+		return nil, false
+	}
+	return s.current, true
+}
+
+func writeJSON(logger *log.Logger, w http.ResponseWriter, statusCode int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Println("failed to write response", err)
+	}
+}
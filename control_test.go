@@ -0,0 +1,109 @@
+package sacura
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExtendableTimeout(t *testing.T) {
+	timeout := newExtendableTimeout(100 * time.Millisecond)
+	timeout.extend(100 * time.Millisecond)
+
+	start := time.Now()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		timeout.extend(100 * time.Millisecond)
+	}()
+	timeout.wait()
+
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Errorf("want timeout of at least 300ms, got %v", elapsed)
+	}
+}
+
+func TestControlServer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := httptest.NewServer(NewControlServer(ctx, "token"))
+	defer s.Close()
+
+	doWithToken := func(method, path, body, token string, wantStatusCode int) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(method, s.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != wantStatusCode {
+			t.Fatalf("%s %s: want status code %d, got %d", method, path, wantStatusCode, resp.StatusCode)
+		}
+		return resp
+	}
+	do := func(method, path, body string, wantStatusCode int) *http.Response {
+		t.Helper()
+		return doWithToken(method, path, body, "token", wantStatusCode)
+	}
+
+	doWithToken(http.MethodGet, "/runs/current", "", "", http.StatusUnauthorized)
+	doWithToken(http.MethodGet, "/runs/current", "", "other", http.StatusUnauthorized)
+	do(http.MethodGet, "/runs/current", "", http.StatusNotFound)
+	do(http.MethodPost, "/runs", "invalid", http.StatusBadRequest)
+
+	config := `
+sender:
+  disabled: true
+receiver:
+  port: 9202
+  timeout: 100ms
+duration: 1m
+runId: control
+metrics:
+  address: 127.0.0.1:0
+`
+	resp := do(http.MethodPost, "/runs", config, http.StatusAccepted)
+	resp.Body.Close()
+	do(http.MethodPost, "/runs", config, http.StatusConflict)
+	do(http.MethodGet, "/runs/current/report", "", http.StatusConflict)
+	do(http.MethodPost, "/runs/current/timeout?extend=invalid", "", http.StatusBadRequest)
+	do(http.MethodPost, "/runs/current/timeout?extend=100ms", "", http.StatusAccepted)
+	do(http.MethodPost, "/runs/current/stop", "", http.StatusAccepted)
+
+	var state RunState
+	for i := 0; i < 100 && !state.Finished; i++ {
+		time.Sleep(100 * time.Millisecond)
+		resp := do(http.MethodGet, "/runs/current", "", http.StatusOK)
+		state = RunState{}
+		if err := json.NewDecoder(resp.Body).Decode(&state); err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if !state.Finished {
+		t.Fatal("run didn't finish")
+	}
+	if state.RunID != "control" || state.Verdict == nil || !state.Verdict.Passed || state.Progress == nil {
+		t.Errorf("unexpected run state %+v", state)
+	}
+
+	resp = do(http.MethodGet, "/runs/current/report?format=json", "", http.StatusOK)
+	defer resp.Body.Close()
+	report := Report{}
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if report.RunID != "control" || !report.Verdict.Passed {
+		t.Errorf("unexpected report %+v", report)
+	}
+}
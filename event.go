@@ -0,0 +1,137 @@
+package sacura
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"text/template"
+	"time"
+
+	ce "github.com/cloudevents/sdk-go/v2"
+	cetest "github.com/cloudevents/sdk-go/v2/test"
+)
+
+const (
+	defaultRandomDataContentType = "application/octet-stream"
+	defaultTextDataContentType   = "text/plain"
+)
+
+// EventGenerator generates an event with the given ID.
+type EventGenerator func(id string) (ce.Event, error)
+
+// NewEventGenerator creates an EventGenerator for the given configuration.
+//
+// When config is nil, the generated events are cetest.FullEvent events.
+func NewEventGenerator(config *EventConfig) (EventGenerator, error) {
+	if config == nil {
+		return func(id string) (ce.Event, error) {
+			event := cetest.FullEvent()
+			event.SetID(id)
+			return event, nil
+		}, nil
+	}
+
+	data, random, err := newDataGenerator(config.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(id string) (ce.Event, error) {
+		event := cetest.FullEvent()
+		event.SetID(id)
+
+		if config.Type != "" {
+			event.SetType(config.Type)
+		}
+		if config.Source != "" {
+			event.SetSource(config.Source)
+		}
+		if config.Subject != "" {
+			event.SetSubject(config.Subject)
+		}
+		if config.DataSchema != "" {
+			event.SetDataSchema(config.DataSchema)
+		}
+		for k, v := range config.Extensions {
+			event.SetExtension(k, v)
+		}
+
+		if data != nil {
+			b, err := data(id)
+			if err != nil {
+				return event, err
+			}
+			if random {
+				event.SetDataContentType(defaultRandomDataContentType)
+				// Random data is encoded as base64 in structured mode.
+				event.DataBase64 = true
+			} else {
+				event.SetDataContentType(defaultTextDataContentType)
+				event.DataBase64 = false
+			}
+			if config.DataContentType != "" {
+				event.SetDataContentType(config.DataContentType)
+			}
+			event.DataEncoded = b
+		} else if config.DataContentType != "" {
+			event.SetDataContentType(config.DataContentType)
+		}
+
+		if err := event.Validate(); err != nil {
+			return event, fmt.Errorf("invalid event: %w", err)
+		}
+		return event, nil
+	}, nil
+}
+
+// newDataGenerator creates a function generating the data of the event with the given ID and whether the
+// generated data is random.
+//
+// It returns a nil function when the event data isn't configured.
+func newDataGenerator(config *DataConfig) (func(id string) ([]byte, error), bool, error) {
+	switch {
+	case config == nil:
+		return nil, false, nil
+	case config.Size > 0:
+		return func(string) ([]byte, error) {
+			return randomData(config.Size), nil
+		}, true, nil
+	case config.MaxSize > 0:
+		return func(string) ([]byte, error) {
+			return randomData(config.MinSize + rand.Intn(config.MaxSize-config.MinSize+1)), nil
+		}, true, nil
+	case config.File != "":
+		b, err := ioutil.ReadFile(config.File)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to read data file %s: %w", config.File, err)
+		}
+		return func(string) ([]byte, error) {
+			return b, nil
+		}, false, nil
+	case config.Template != "":
+		t, err := template.New("data").Parse(config.Template)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to parse data template: %w", err)
+		}
+		return func(id string) ([]byte, error) {
+			var b bytes.Buffer
+			if err := t.Execute(&b, dataTemplateInput{ID: id, Time: time.Now()}); err != nil {
+				return nil, fmt.Errorf("failed to execute data template: %w", err)
+			}
+			return b.Bytes(), nil
+		}, false, nil
+	}
+	return nil, false, nil
+}
+
+type dataTemplateInput struct {
+	ID   string
+	Time time.Time
+}
+
+func randomData(size int) []byte {
+	b := make([]byte, size)
+	_, _ = rand.Read(b)
+	return b
+}
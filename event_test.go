@@ -0,0 +1,110 @@
+package sacura
+
+import (
+	"testing"
+
+	cetest "github.com/cloudevents/sdk-go/v2/test"
+)
+
+func TestNewEventGenerator(t *testing.T) {
+
+	tests := []struct {
+		name            string
+		config          *EventConfig
+		wantType        string
+		wantContentType string
+		wantData        string
+		wantMinDataSize int
+		wantMaxDataSize int
+	}{
+		{
+			name:            "default",
+			config:          nil,
+			wantType:        cetest.FullEvent().Type(),
+			wantContentType: cetest.FullEvent().DataContentType(),
+			wantData:        string(cetest.FullEvent().Data()),
+		},
+		{
+			name: "attributes",
+			config: &EventConfig{
+				Type:            "dev.sacura.test",
+				Source:          "/sacura",
+				Extensions:      map[string]string{"myextension": "value"},
+				DataContentType: "application/json",
+			},
+			wantType:        "dev.sacura.test",
+			wantContentType: "application/json",
+			wantData:        string(cetest.FullEvent().Data()),
+		},
+		{
+			name: "fixed size",
+			config: &EventConfig{
+				Data: &DataConfig{Size: 64 * 1024},
+			},
+			wantType:        cetest.FullEvent().Type(),
+			wantContentType: defaultRandomDataContentType,
+			wantMinDataSize: 64 * 1024,
+			wantMaxDataSize: 64 * 1024,
+		},
+		{
+			name: "size distribution",
+			config: &EventConfig{
+				Data: &DataConfig{MinSize: 10, MaxSize: 100},
+			},
+			wantType:        cetest.FullEvent().Type(),
+			wantContentType: defaultRandomDataContentType,
+			wantMinDataSize: 10,
+			wantMaxDataSize: 100,
+		},
+		{
+			name: "template",
+			config: &EventConfig{
+				DataContentType: "application/json",
+				Data:            &DataConfig{Template: `{"id": "{{.ID}}"}`},
+			},
+			wantType:        cetest.FullEvent().Type(),
+			wantContentType: "application/json",
+			wantData:        `{"id": "1"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newEvent, err := NewEventGenerator(tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i < 10; i++ {
+				event, err := newEvent("1")
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if event.ID() != "1" {
+					t.Errorf("want ID 1, got %s", event.ID())
+				}
+				if event.Type() != tt.wantType {
+					t.Errorf("want type %s, got %s", tt.wantType, event.Type())
+				}
+				if event.DataContentType() != tt.wantContentType {
+					t.Errorf("want content type %s, got %s", tt.wantContentType, event.DataContentType())
+				}
+				if tt.config != nil {
+					for k, v := range tt.config.Extensions {
+						if got := event.Extensions()[k]; got != v {
+							t.Errorf("want extension %s=%s, got %v", k, v, got)
+						}
+					}
+				}
+				if tt.wantMaxDataSize > 0 {
+					if size := len(event.Data()); size < tt.wantMinDataSize || size > tt.wantMaxDataSize {
+						t.Errorf("want data size between %d and %d, got %d", tt.wantMinDataSize, tt.wantMaxDataSize, size)
+					}
+				} else if string(event.Data()) != tt.wantData {
+					t.Errorf("want data %s, got %s", tt.wantData, string(event.Data()))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package sacura
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// fault is a fault injected by the receiver in place of a successful response.
+type fault struct {
+	drop       bool
+	statusCode int
+	retryAfter *time.Duration
+}
+
+// forPath returns the fault configuration for the given request path.
+func (f *ReceiverFaultConfig) forPath(path string) *ReceiverFaultConfig {
+	if f == nil {
+		return nil
+	}
+	if p, ok := f.Paths[path]; ok && p != nil {
+		return p
+	}
+	return f
+}
+
+// pickFault picks the fault to inject for the given request path, it returns nil when no fault has to be injected.
+func pickFault(config *ReceiverFaultConfig, path string) *fault {
+	config = config.forPath(path)
+	if config == nil || (config.DropProbability == 0 && config.ErrorProbability == 0) {
+		return nil
+	}
+
+	p := rand.Float64()
+	if p < config.DropProbability {
+		return &fault{drop: true}
+	}
+	if p < config.DropProbability+config.ErrorProbability {
+		statusCode := http.StatusInternalServerError
+		if len(config.ErrorStatusCodes) > 0 {
+			statusCode = config.ErrorStatusCodes[rand.Intn(len(config.ErrorStatusCodes))]
+		}
+		return &fault{statusCode: statusCode, retryAfter: config.ParsedRetryAfter}
+	}
+	return nil
+}
+
+func (f *fault) respond(logger *log.Logger, w http.ResponseWriter) {
+	if f.drop {
+		hj, ok := w.(http.Hijacker)
+		if !ok {
+			// Closing the connection isn't possible, respond with an error, so that events are still not received.
+			http.Error(w, "injected fault: drop", http.StatusInternalServerError)
+			return
+		}
+		conn, _, err := hj.Hijack()
+		if err != nil {
+			logger.Println("failed to hijack connection", err)
+			return
+		}
+		_ = conn.Close()
+		return
+	}
+
+	if f.retryAfter != nil {
+		w.Header().Set("Retry-After", fmt.Sprint(int64(math.Ceil(f.retryAfter.Seconds()))))
+	}
+	http.Error(w, "injected fault", f.statusCode)
+}
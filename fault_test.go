@@ -0,0 +1,113 @@
+package sacura
+
+import (
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPickFault(t *testing.T) {
+
+	retryAfter := 1500 * time.Millisecond
+
+	tests := []struct {
+		name           string
+		config         *ReceiverFaultConfig
+		path           string
+		want           *fault
+		wantRetryAfter string
+	}{
+		{
+			name:   "no config",
+			config: nil,
+			path:   "/",
+			want:   nil,
+		},
+		{
+			name:   "no faults",
+			config: &ReceiverFaultConfig{},
+			path:   "/",
+			want:   nil,
+		},
+		{
+			name:   "drop",
+			config: &ReceiverFaultConfig{DropProbability: 1},
+			path:   "/",
+			want:   &fault{drop: true},
+		},
+		{
+			name:   "default error status code",
+			config: &ReceiverFaultConfig{ErrorProbability: 1},
+			path:   "/",
+			want:   &fault{statusCode: http.StatusInternalServerError},
+		},
+		{
+			name: "error with retry after",
+			config: &ReceiverFaultConfig{
+				ErrorProbability: 1,
+				ErrorStatusCodes: []int{http.StatusTooManyRequests},
+				RetryAfter:       "1500ms",
+				ParsedRetryAfter: &retryAfter,
+			},
+			path:           "/",
+			want:           &fault{statusCode: http.StatusTooManyRequests, retryAfter: &retryAfter},
+			wantRetryAfter: "2",
+		},
+		{
+			name: "path override",
+			config: &ReceiverFaultConfig{
+				ErrorProbability: 1,
+				Paths: map[string]*ReceiverFaultConfig{
+					"/healthy": {},
+				},
+			},
+			path: "/healthy",
+			want: nil,
+		},
+		{
+			name: "path not matching",
+			config: &ReceiverFaultConfig{
+				Paths: map[string]*ReceiverFaultConfig{
+					"/faulty": {ErrorProbability: 1, ErrorStatusCodes: []int{http.StatusServiceUnavailable}},
+				},
+			},
+			path: "/",
+			want: nil,
+		},
+		{
+			name: "path matching",
+			config: &ReceiverFaultConfig{
+				Paths: map[string]*ReceiverFaultConfig{
+					"/faulty": {ErrorProbability: 1, ErrorStatusCodes: []int{http.StatusServiceUnavailable}},
+				},
+			},
+			path: "/faulty",
+			want: &fault{statusCode: http.StatusServiceUnavailable},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := pickFault(tt.config, tt.path)
+			if diff := cmp.Diff(tt.want, got, cmp.AllowUnexported(fault{})); diff != "" {
+				t.Fatal("(-want, +got)", diff)
+			}
+			if got == nil || got.drop {
+				return
+			}
+
+			w := httptest.NewRecorder()
+			got.respond(log.Default(), w)
+			if w.Code != tt.want.statusCode {
+				t.Errorf("want status code %d, got %d", tt.want.statusCode, w.Code)
+			}
+			if v := w.Header().Get("Retry-After"); v != tt.wantRetryAfter {
+				t.Errorf("want Retry-After %q, got %q", tt.wantRetryAfter, v)
+			}
+		})
+	}
+}
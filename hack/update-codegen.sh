@@ -6,3 +6,5 @@ go mod tidy
 go mod vendor
 
 git apply hack/patches/*.patch
+
+go run ./cmd/sacura schema > schema/config.schema.json
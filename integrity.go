@@ -0,0 +1,113 @@
+package sacura
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	ce "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/types"
+)
+
+const (
+	// DataChecksumExtension is the extension holding the SHA-256 checksum of the event data.
+	DataChecksumExtension = "sacuradatachecksum"
+	// AttributesChecksumExtension is the extension holding the SHA-256 checksum of the event attributes and of the
+	// extensions listed in ChecksumExtensionsExtension.
+	AttributesChecksumExtension = "sacuraattributeschecksum"
+	// ChecksumExtensionsExtension is the extension holding the comma separated list of extensions covered by
+	// AttributesChecksumExtension.
+	//
+	// Extensions added by the system under test aren't covered, so that they aren't reported as mutated attributes.
+	ChecksumExtensionsExtension = "sacurachecksumextensions"
+)
+
+// IntegrityViolation is the kind of integrity violation of a received event.
+type IntegrityViolation string
+
+const (
+	// CorruptedDataViolation is reported when the event data doesn't match the data sent.
+	CorruptedDataViolation IntegrityViolation = "corruptedData"
+	// MutatedAttributesViolation is reported when the event attributes or extensions don't match the ones sent.
+	MutatedAttributesViolation IntegrityViolation = "mutatedAttributes"
+	// MissingExtensionsViolation is reported when the checksum extensions or an extension covered by the checksum is
+	// missing.
+	MissingExtensionsViolation IntegrityViolation = "missingExtensions"
+)
+
+// stampChecksums sets the checksum extensions on the given event, it must be called after any other change to the
+// event.
+func stampChecksums(e *ce.Event) {
+	extensions := make([]string, 0, len(e.Extensions()))
+	for name := range e.Extensions() {
+		if !isChecksumExtension(name) {
+			extensions = append(extensions, name)
+		}
+	}
+	sort.Strings(extensions)
+
+	e.SetExtension(DataChecksumExtension, dataChecksum(e))
+	e.SetExtension(AttributesChecksumExtension, attributesChecksum(e, extensions))
+	e.SetExtension(ChecksumExtensionsExtension, strings.Join(extensions, ","))
+}
+
+// verifyChecksums verifies the checksum extensions of the given event, it returns the violations found.
+func verifyChecksums(e *ce.Event) []IntegrityViolation {
+	ext := e.Extensions()
+	dataSum, hasDataSum := ext[DataChecksumExtension]
+	attributesSum, hasAttributesSum := ext[AttributesChecksumExtension]
+	names, hasNames := ext[ChecksumExtensionsExtension]
+	if !hasDataSum || !hasAttributesSum || !hasNames {
+		return []IntegrityViolation{MissingExtensionsViolation}
+	}
+
+	var extensions []string
+	if s, _ := types.ToString(names); s != "" {
+		extensions = strings.Split(s, ",")
+	}
+	for _, name := range extensions {
+		if _, ok := ext[name]; !ok {
+			return []IntegrityViolation{MissingExtensionsViolation}
+		}
+	}
+
+	var violations []IntegrityViolation
+	if s, _ := types.ToString(dataSum); s != dataChecksum(e) {
+		violations = append(violations, CorruptedDataViolation)
+	}
+	if s, _ := types.ToString(attributesSum); s != attributesChecksum(e, extensions) {
+		violations = append(violations, MutatedAttributesViolation)
+	}
+	return violations
+}
+
+func isChecksumExtension(name string) bool {
+	return name == DataChecksumExtension || name == AttributesChecksumExtension || name == ChecksumExtensionsExtension
+}
+
+func dataChecksum(e *ce.Event) string {
+	sum := sha256.Sum256(e.Data())
+	return hex.EncodeToString(sum[:])
+}
+
+func attributesChecksum(e *ce.Event, extensions []string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "specversion=%s\n", e.SpecVersion())
+	fmt.Fprintf(h, "id=%s\n", e.ID())
+	fmt.Fprintf(h, "source=%s\n", e.Source())
+	fmt.Fprintf(h, "type=%s\n", e.Type())
+	fmt.Fprintf(h, "subject=%s\n", e.Subject())
+	fmt.Fprintf(h, "datacontenttype=%s\n", e.DataContentType())
+	fmt.Fprintf(h, "dataschema=%s\n", e.DataSchema())
+	if t := e.Time(); !t.IsZero() {
+		fmt.Fprintf(h, "time=%s\n", types.FormatTime(t))
+	}
+	ext := e.Extensions()
+	for _, name := range extensions {
+		v, _ := types.Format(ext[name])
+		fmt.Fprintf(h, "%s=%s\n", name, v)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,86 @@
+package sacura
+
+import (
+	"testing"
+
+	ce "github.com/cloudevents/sdk-go/v2"
+	cetest "github.com/cloudevents/sdk-go/v2/test"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestVerifyChecksums(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		mutate func(e *ce.Event)
+		want   []IntegrityViolation
+	}{
+		{
+			name:   "valid",
+			mutate: func(e *ce.Event) {},
+		},
+		{
+			name: "added extension",
+			mutate: func(e *ce.Event) {
+				e.SetExtension("brokerextension", "value")
+			},
+		},
+		{
+			name: "corrupted data",
+			mutate: func(e *ce.Event) {
+				e.DataEncoded = []byte("corrupted")
+			},
+			want: []IntegrityViolation{CorruptedDataViolation},
+		},
+		{
+			name: "mutated attribute",
+			mutate: func(e *ce.Event) {
+				e.SetType("dev.sacura.mutated")
+			},
+			want: []IntegrityViolation{MutatedAttributesViolation},
+		},
+		{
+			name: "mutated extension",
+			mutate: func(e *ce.Event) {
+				e.SetExtension(BenchmarkTimestampAttribute, "0")
+			},
+			want: []IntegrityViolation{MutatedAttributesViolation},
+		},
+		{
+			name: "corrupted data and mutated attribute",
+			mutate: func(e *ce.Event) {
+				e.DataEncoded = []byte("corrupted")
+				e.SetSubject("mutated")
+			},
+			want: []IntegrityViolation{CorruptedDataViolation, MutatedAttributesViolation},
+		},
+		{
+			name: "missing extension",
+			mutate: func(e *ce.Event) {
+				e.SetExtension(BenchmarkTimestampAttribute, nil)
+			},
+			want: []IntegrityViolation{MissingExtensionsViolation},
+		},
+		{
+			name: "missing checksum",
+			mutate: func(e *ce.Event) {
+				e.SetExtension(DataChecksumExtension, nil)
+			},
+			want: []IntegrityViolation{MissingExtensionsViolation},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := cetest.FullEvent()
+			e.SetExtension(BenchmarkTimestampAttribute, "1640995200000")
+			stampChecksums(&e)
+
+			tt.mutate(&e)
+
+			if diff := cmp.Diff(tt.want, verifyChecksums(&e)); diff != "" {
+				t.Errorf("(-want, +got) %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package sacura
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// jwks is a JSON Web Key Set, only RSA and EC public keys are supported.
+type jwks struct {
+	// keys are the keys by key ID.
+	keys map[string]crypto.PublicKey
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	// N and E are the RSA modulus and exponent.
+	N string `json:"n"`
+	E string `json:"e"`
+	// Crv, X and Y are the EC curve and coordinates.
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func loadJWKS(path string) (*jwks, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS file: %w", err)
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &set); err != nil {
+		return nil, fmt.Errorf("failed to decode JWKS file %s: %w", path, err)
+	}
+
+	keys := &jwks{keys: make(map[string]crypto.PublicKey, len(set.Keys))}
+	for i, k := range set.Keys {
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %d (kid %q) in JWKS file %s: %w", i, k.Kid, path, err)
+		}
+		if _, ok := keys.keys[k.Kid]; ok {
+			return nil, fmt.Errorf("duplicate kid %q in JWKS file %s", k.Kid, path)
+		}
+		keys.keys[k.Kid] = key
+	}
+	if len(keys.keys) == 0 {
+		return nil, fmt.Errorf("no keys in JWKS file %s", path)
+	}
+	return keys, nil
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid n: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid e: %w", err)
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid e: exponent too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x: %w", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y: %w", err)
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point isn't on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// key returns the key with the given ID, when the ID is empty and the set has a single key, that key is returned.
+func (s *jwks) key(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, k := range s.keys {
+			return k, true
+		}
+	}
+	k, ok := s.keys[kid]
+	return k, ok
+}
+
+type jwtClaims struct {
+	Issuer    string          `json:"iss"`
+	Audience  json.RawMessage `json:"aud"`
+	ExpiresAt *float64        `json:"exp"`
+	NotBefore *float64        `json:"nbf"`
+}
+
+// verifyJWT verifies the signature and the claims of the given token, it returns an empty AuthRejection when the
+// token is valid.
+func verifyJWT(token string, keys *jwks, config *JWTAuthConfig, now time.Time) AuthRejection {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return MalformedTokenRejection
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return MalformedTokenRejection
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return MalformedTokenRejection
+	}
+	key, ok := keys.key(header.Kid)
+	if !ok {
+		return InvalidSignatureRejection
+	}
+	if !verifyJWTSignature(header.Alg, key, parts[0]+"."+parts[1], signature) {
+		return InvalidSignatureRejection
+	}
+
+	var claims jwtClaims
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return MalformedTokenRejection
+	}
+	if claims.ExpiresAt != nil && now.Unix() >= int64(*claims.ExpiresAt) {
+		return ExpiredTokenRejection
+	}
+	if claims.NotBefore != nil && now.Unix() < int64(*claims.NotBefore) {
+		return ExpiredTokenRejection
+	}
+	if config.Issuer != "" && claims.Issuer != config.Issuer {
+		return InvalidIssuerRejection
+	}
+	if config.Audience != "" && !hasAudience(claims.Audience, config.Audience) {
+		return InvalidAudienceRejection
+	}
+	return ""
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// hasAudience returns whether the aud claim, a string or an array of strings, contains audience.
+func hasAudience(aud json.RawMessage, audience string) bool {
+	var single string
+	if err := json.Unmarshal(aud, &single); err == nil {
+		return single == audience
+	}
+	var multiple []string
+	if err := json.Unmarshal(aud, &multiple); err == nil {
+		for _, a := range multiple {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func verifyJWTSignature(alg string, key crypto.PublicKey, signed string, signature []byte) bool {
+	if len(alg) != 5 {
+		return false
+	}
+	var hash crypto.Hash
+	switch alg[len(alg)-3:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	default:
+		return false
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	switch alg[:2] {
+	case "RS":
+		k, ok := key.(*rsa.PublicKey)
+		return ok && rsa.VerifyPKCS1v15(k, hash, digest, signature) == nil
+	case "PS":
+		k, ok := key.(*rsa.PublicKey)
+		return ok && rsa.VerifyPSS(k, hash, digest, signature, nil) == nil
+	case "ES":
+		k, ok := key.(*ecdsa.PublicKey)
+		if !ok || !curveMatchesHash(k.Curve, hash) {
+			return false
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(k, digest, r, s)
+	}
+	return false
+}
+
+// curveMatchesHash returns whether the curve is the one ES256, ES384 or ES512 requires for the given hash.
+func curveMatchesHash(curve elliptic.Curve, hash crypto.Hash) bool {
+	switch hash {
+	case crypto.SHA256:
+		return curve == elliptic.P256()
+	case crypto.SHA384:
+		return curve == elliptic.P384()
+	case crypto.SHA512:
+		return curve == elliptic.P521()
+	}
+	return false
+}
@@ -0,0 +1,230 @@
+package sacura
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTokenVerifier(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	writeTestJWKS(t, jwksFile, map[string]crypto.PublicKey{"rsa": &rsaKey.PublicKey, "ec": &ecKey.PublicKey})
+
+	jwtConfig := &ReceiverAuthConfig{
+		JWT: &JWTAuthConfig{JWKSFile: jwksFile, Audience: "sacura", Issuer: "https://issuer"},
+	}
+	valid := map[string]interface{}{
+		"iss": "https://issuer",
+		"aud": []string{"other", "sacura"},
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	with := func(claims map[string]interface{}, key string, value interface{}) map[string]interface{} {
+		c := make(map[string]interface{}, len(claims))
+		for k, v := range claims {
+			c[k] = v
+		}
+		c[key] = value
+		return c
+	}
+
+	tests := []struct {
+		name          string
+		config        *ReceiverAuthConfig
+		authorization string
+		want          AuthRejection
+	}{
+		{
+			name:   "no auth",
+			config: nil,
+			want:   "",
+		},
+		{
+			name:          "static token",
+			config:        &ReceiverAuthConfig{BearerToken: "token"},
+			authorization: "Bearer token",
+			want:          "",
+		},
+		{
+			name:          "wrong static token",
+			config:        &ReceiverAuthConfig{BearerToken: "token"},
+			authorization: "Bearer other",
+			want:          InvalidTokenRejection,
+		},
+		{
+			name:   "missing token",
+			config: &ReceiverAuthConfig{BearerToken: "token"},
+			want:   MissingTokenRejection,
+		},
+		{
+			name:          "basic auth",
+			config:        &ReceiverAuthConfig{BearerToken: "token"},
+			authorization: "Basic dXNlcjpwYXNz",
+			want:          MissingTokenRejection,
+		},
+		{
+			name:          "RS256",
+			config:        jwtConfig,
+			authorization: "Bearer " + signTestJWT(t, "RS256", "rsa", rsaKey, valid),
+			want:          "",
+		},
+		{
+			name:          "ES256",
+			config:        jwtConfig,
+			authorization: "Bearer " + signTestJWT(t, "ES256", "ec", ecKey, valid),
+			want:          "",
+		},
+		{
+			name:          "wrong audience",
+			config:        jwtConfig,
+			authorization: "Bearer " + signTestJWT(t, "RS256", "rsa", rsaKey, with(valid, "aud", "other")),
+			want:          InvalidAudienceRejection,
+		},
+		{
+			name:          "wrong issuer",
+			config:        jwtConfig,
+			authorization: "Bearer " + signTestJWT(t, "RS256", "rsa", rsaKey, with(valid, "iss", "https://other")),
+			want:          InvalidIssuerRejection,
+		},
+		{
+			name:          "expired",
+			config:        jwtConfig,
+			authorization: "Bearer " + signTestJWT(t, "RS256", "rsa", rsaKey, with(valid, "exp", time.Now().Add(-time.Minute).Unix())),
+			want:          ExpiredTokenRejection,
+		},
+		{
+			name:          "not yet valid",
+			config:        jwtConfig,
+			authorization: "Bearer " + signTestJWT(t, "RS256", "rsa", rsaKey, with(valid, "nbf", time.Now().Add(time.Hour).Unix())),
+			want:          ExpiredTokenRejection,
+		},
+		{
+			name:          "signed with another key",
+			config:        jwtConfig,
+			authorization: "Bearer " + signTestJWT(t, "RS256", "rsa", otherKey, valid),
+			want:          InvalidSignatureRejection,
+		},
+		{
+			name:          "unknown kid",
+			config:        jwtConfig,
+			authorization: "Bearer " + signTestJWT(t, "RS256", "unknown", rsaKey, valid),
+			want:          InvalidSignatureRejection,
+		},
+		{
+			name:          "algorithm not matching the key",
+			config:        jwtConfig,
+			authorization: "Bearer " + signTestJWT(t, "ES256", "rsa", ecKey, valid),
+			want:          InvalidSignatureRejection,
+		},
+		{
+			name:          "malformed",
+			config:        jwtConfig,
+			authorization: "Bearer token",
+			want:          MalformedTokenRejection,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := newTokenVerifier(tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, _ := http.NewRequest(http.MethodPost, "http://localhost", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			if got := v.verify(r); got != tt.want {
+				t.Errorf("want rejection %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func writeTestJWKS(t *testing.T, path string, keys map[string]crypto.PublicKey) {
+	t.Helper()
+
+	encode := func(i *big.Int) string {
+		return base64.RawURLEncoding.EncodeToString(i.Bytes())
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	for kid, key := range keys {
+		switch k := key.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, jsonWebKey{Kty: "RSA", Kid: kid, N: encode(k.N), E: encode(big.NewInt(int64(k.E)))})
+		case *ecdsa.PublicKey:
+			set.Keys = append(set.Keys, jsonWebKey{Kty: "EC", Kid: kid, Crv: k.Curve.Params().Name, X: encode(k.X), Y: encode(k.Y)})
+		default:
+			t.Fatalf("unsupported key %T", key)
+		}
+	}
+	b, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// signTestJWT signs claims with key, alg is only used as header, the signature is RS256 for RSA keys and ES256 for EC
+// keys.
+func signTestJWT(t *testing.T, alg string, kid string, key crypto.Signer, claims map[string]interface{}) string {
+	t.Helper()
+
+	encode := func(v interface{}) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	signed := encode(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		s, err := rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = s
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	default:
+		t.Fatalf("unsupported key %T", key)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
@@ -6,69 +6,260 @@ import (
 	"fmt"
 	"log"
 	"math"
+	"os"
 	"time"
 
 	ce "github.com/cloudevents/sdk-go/v2"
 )
 
 func Main(ctx context.Context, config Config) error {
+	return run(ctx, NewRunControl(config))
+}
+
+// run runs the run controlled by the given RunControl, panics are returned as errors.
+func run(ctx context.Context, rc *RunControl) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("run panicked: %v", r)
+		}
+		rc.finish(err)
+	}()
+
+	config := rc.config
+	logger := rc.logger
 
 	c, _ := json.Marshal(&config)
-	log.Println("config", string(c))
+	logger.Println("config", string(c))
+
+	// Metrics are exported until the run ends, since the receiver keeps receiving events after ctx is done.
+	metricsCtx, stopMetrics := context.WithCancel(context.Background())
+	meter, wait, err := exportMetrics(metricsCtx, logger, config.Metrics)
+	if err != nil {
+		stopMetrics()
+		return err
+	}
+	defer func() {
+		stopMetrics()
+		wait()
+	}()
 
 	ctx, cancel := context.WithCancel(ctx)
+	if config.Sender.Disabled {
+		go func() {
+			select {
+			case <-rc.senderCtx.Done():
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
 
-	log.Println("Creating channels")
+	logger.Println("Creating channels")
 	buffer := int(math.Min(float64(int(config.ParsedDuration)*config.Sender.FrequencyPerSecond), math.MaxInt8))
+	if len(config.Scenario) > 0 {
+		// Phases might override the sender frequency.
+		buffer = math.MaxInt8
+	}
 
 	sent := make(chan ce.Event, buffer)
 	received := make(chan ce.Event, buffer)
 	var metrics Metrics
+	var senderErr error
 
 	go func() {
 		defer close(sent)
+		defer func() {
+			if r := recover(); r != nil {
+				senderErr = fmt.Errorf("sender panicked: %v", r)
+			}
+		}()
 
 		if !config.Sender.Disabled {
 			defer cancel()
-			log.Println("Starting attacker ...")
-			time.Sleep(time.Second * 10) // Waiting for receiver to start
-			metrics = StartSender(config, sent)
+			select {
+			case <-time.After(time.Second * 10): // Waiting for receiver to start
+			case <-rc.senderCtx.Done():
+				return
+			}
+			logger.Println("Starting attacker ...")
+			metrics, senderErr = startSender(rc.senderCtx, logger, meter, config, sent)
 		}
 	}()
 
-	log.Println("Creating state manager ...")
+	logger.Println("Creating state manager ...")
 	sm := NewStateManager(config)
+	rc.setStateManager(sm)
 	receivedSignal := sm.ReadReceived(received)
 	sentSignal := sm.ReadSent(sent)
+	if err := registerStateMetrics(meter, sm); err != nil {
+		logger.Println("failed to register state metrics", err)
+	}
 
-	log.Println("Starting receiver ...")
-	if err := StartReceiver(ctx, config.Receiver, received); err != nil {
-		return fmt.Errorf("failed to start receiver: %w", err)
+	if config.Receiver.Disabled {
+		logger.Println("Receiver disabled")
+		close(received)
+	} else {
+		logger.Println("Starting receiver ...")
+		if err := startReceiverWithTimeout(ctx, logger, meter, config.Receiver, rc.timeout, received, sm); err != nil {
+			return fmt.Errorf("failed to start receiver: %w", err)
+		}
 	}
 
 	if !config.Sender.Disabled {
-		log.Println("Waiting for attacker to finish ...")
+		logger.Println("Waiting for attacker to finish ...")
 	} else {
-		log.Println("Waiting for term signals")
+		logger.Println("Waiting for term signals")
 	}
 	<-ctx.Done()
 
-	log.Println("Waiting for received channel signal")
+	logger.Println("Waiting for received channel signal")
 	<-receivedSignal
 
-	log.Println("Waiting for sent channel signal")
+	logger.Println("Waiting for sent channel signal")
 	<-sentSignal
 
+	if senderErr != nil {
+		return fmt.Errorf("failed to start sender: %w", senderErr)
+	}
+
 	sm.Terminated(metrics)
+
+	if config.StateFile != "" {
+		if err := WriteState(config.StateFile, sm.ExportState()); err != nil {
+			return err
+		}
+		logger.Println("State written to", config.StateFile)
+	}
+
 	report := sm.GenerateReport()
-	logReport(report)
+	err = verify(logger, config, report)
+	report.Verdict = newVerdict(err)
+	logReport(logger, report)
+	rc.setReport(report)
+
+	if config.Report != nil && config.Report.File != "" {
+		if writeErr := writeReportFile(logger, config.Report, report); writeErr != nil {
+			if err != nil {
+				logger.Println(writeErr)
+				return err
+			}
+			return writeErr
+		}
+	}
+
+	return err
+}
+
+// ReconcileMain reconciles the states exported by the sender and the receivers of a run into one report, which is
+// logged and written to the configured report file.
+func ReconcileMain(config Config, states []State) error {
+	logger := log.Default()
+
+	report, err := Reconcile(config, states)
+	if err != nil {
+		return fmt.Errorf("failed to reconcile states: %w", err)
+	}
+	err = verify(logger, reconciledConfig(config), report)
+	report.Verdict = newVerdict(err)
+	logReport(logger, report)
+
+	if config.Report != nil && config.Report.File != "" {
+		if writeErr := writeReportFile(logger, config.Report, report); writeErr != nil {
+			if err != nil {
+				logger.Println(writeErr)
+				return err
+			}
+			return writeErr
+		}
+	}
 
+	return err
+}
+
+func writeReportFile(logger *log.Logger, config *ReportConfig, report Report) error {
+	f, err := os.Create(config.File)
+	if err != nil {
+		return fmt.Errorf("failed to create report file %s: %w", config.File, err)
+	}
+	defer f.Close()
+
+	if err := WriteReport(f, config.Format, report); err != nil {
+		return fmt.Errorf("failed to write report file %s: %w", config.File, err)
+	}
+	logger.Println("Report written to", config.File)
+	return f.Close()
+}
+
+// verify checks the report against the configured delivery guarantee and thresholds.
+func verify(logger *log.Logger, config Config, report Report) error {
 	if !config.Sender.Disabled && report.Metrics.AcceptedCount == 0 {
 		return fmt.Errorf("no events were accepted: %+v", report.Metrics)
 	}
 
-	if lost := report.Metrics.AcceptedCount - report.ReceivedCount; !config.Sender.Disabled && lost != 0 {
-		return fmt.Errorf("lost count (accepted but not received): %d - %d = %d", report.Metrics.AcceptedCount, sm.ReceivedCount(), lost)
+	if config.Receiver.Disabled {
+		// Received events are only known once the states of the receivers are reconciled.
+		return nil
+	}
+
+	if lost := report.Metrics.AcceptedCount - report.ReceivedCount; !config.Sender.Disabled && lost != 0 && !config.DeliveryGuarantee.AllowsLoss() {
+		return fmt.Errorf("lost count (accepted but not received) with %s delivery guarantee: %d - %d = %d",
+			config.DeliveryGuarantee,
+			report.Metrics.AcceptedCount,
+			report.ReceivedCount,
+			lost,
+		)
+	}
+
+	if report.UnknownCount > 0 {
+		return fmt.Errorf("received %d events that were never sent, listing events:\n%+v",
+			report.UnknownCount,
+			report.UnknownEventsByPartitionKey,
+		)
+	}
+
+	if report.RejectedReceivedCount > 0 && !config.DeliveryGuarantee.AllowsDuplicates() {
+		// With at least once delivery, the sender would retry rejected events, so delivering them is allowed.
+		return fmt.Errorf("received %d events that were rejected with %s delivery guarantee, listing events:\n%+v",
+			report.RejectedReceivedCount,
+			config.DeliveryGuarantee,
+			report.RejectedReceivedEventsByPartitionKey,
+		)
+	}
+
+	if config.Ordered != nil && config.Ordered.MaxOrderingViolations != nil && report.OrderingViolationCount > *config.Ordered.MaxOrderingViolations {
+		return fmt.Errorf("too many ordering violations detected %d, expected at most %d, listing violations:\n%+v",
+			report.OrderingViolationCount,
+			*config.Ordered.MaxOrderingViolations,
+			report.OrderingViolationsByPartitionKey,
+		)
+	}
+
+	if config.Integrity != nil && config.Integrity.MaxViolations != nil && report.IntegrityViolationCount > *config.Integrity.MaxViolations {
+		return fmt.Errorf("too many integrity violations detected %d, expected at most %d, listing violations:\n%+v",
+			report.IntegrityViolationCount,
+			*config.Integrity.MaxViolations,
+			report.IntegrityViolations,
+		)
+	}
+
+	if auth := config.Receiver.Auth; auth != nil && auth.MaxRejectedEvents != nil && report.AuthRejectedCount > *auth.MaxRejectedEvents {
+		return fmt.Errorf("too many events rejected by the receiver authentication %d, expected at most %d, listing rejections:\n%+v",
+			report.AuthRejectedCount,
+			*auth.MaxRejectedEvents,
+			report.AuthRejections,
+		)
+	}
+
+	if err := verifyReplies(config, report.Replies); err != nil {
+		return err
+	}
+
+	if report.DuplicateCount > 0 && !config.DeliveryGuarantee.AllowsDuplicates() {
+		return fmt.Errorf("duplicates detected %d with %s delivery guarantee, listing duplicates:\n%+v",
+			report.DuplicateCount,
+			config.DeliveryGuarantee,
+			report.DuplicateEventsByPartitionKey,
+		)
 	}
 
 	if report.ReceivedCount > 0 {
@@ -77,7 +268,7 @@ func Main(ctx context.Context, config Config) error {
 		// x = 100 * duplicateCount / (duplicateCount + receivedCount)
 		duplicatesPercentage := 100 * report.DuplicateCount / (report.DuplicateCount + report.ReceivedCount)
 
-		log.Printf("Duplicates percentage %d", duplicatesPercentage)
+		logger.Printf("Duplicates percentage %d", duplicatesPercentage)
 
 		if config.Receiver.MaxDuplicatesPercentage != nil && duplicatesPercentage > *config.Receiver.MaxDuplicatesPercentage {
 			return fmt.Errorf("too many duplicates detected %d, expected at most %d, listing duplicates:\n%+v",
@@ -91,12 +282,45 @@ func Main(ctx context.Context, config Config) error {
 	return nil
 }
 
-func logReport(report Report) {
+// verifyReplies checks the reply events against the configured delivery guarantee, replies must come back to the
+// reply path.
+func verifyReplies(config Config, r *ReplyReport) error {
+	if r == nil {
+		return nil
+	}
+	if r.LostCount > 0 && !config.DeliveryGuarantee.AllowsLoss() {
+		return fmt.Errorf("lost replies (sent but not received) %d with %s delivery guarantee, listing replies:\n%+v",
+			r.LostCount,
+			config.DeliveryGuarantee,
+			r.Lost,
+		)
+	}
+	if r.UnknownCount > 0 {
+		return fmt.Errorf("received %d replies that were never sent, listing replies:\n%+v", r.UnknownCount, r.Unknown)
+	}
+	if r.MisroutedCount > 0 {
+		return fmt.Errorf("received %d replies on a path other than %s, listing replies:\n%+v",
+			r.MisroutedCount,
+			config.Receiver.Reply.Path,
+			r.Misrouted,
+		)
+	}
+	if r.DuplicateCount > 0 && !config.DeliveryGuarantee.AllowsDuplicates() {
+		return fmt.Errorf("duplicate replies detected %d with %s delivery guarantee, listing duplicates:\n%+v",
+			r.DuplicateCount,
+			config.DeliveryGuarantee,
+			r.Duplicates,
+		)
+	}
+	return nil
+}
+
+func logReport(logger *log.Logger, report Report) {
 	jsonReport, err := json.MarshalIndent(report, "", "  ")
 	if err != nil {
-		log.Println("failed to marshal report", err)
+		logger.Println("failed to marshal report", err)
 		return
 	}
 
-	log.Println("report", string(jsonReport))
+	logger.Println("report", string(jsonReport))
 }
@@ -2,6 +2,7 @@ package sacura
 
 import (
 	"context"
+	"log"
 	"os"
 	"testing"
 	"time"
@@ -32,6 +33,14 @@ func Test_Main(t *testing.T) {
 			name: "ordered",
 			path: "test/config-ordered.yaml",
 		},
+		{
+			name: "batch",
+			path: "test/config-batch.yaml",
+		},
+		{
+			name: "receiver fault",
+			path: "test/config-receiver-fault.yaml",
+		},
 		{
 			name: "receiver only",
 			path: "test/config-receiver-only.yaml",
@@ -43,7 +52,11 @@ func Test_Main(t *testing.T) {
 	}
 
 	for _, tc := range tt {
+		tc := tc
 		t.Run(tc.name, func(t *testing.T) {
+			// Every configuration uses its own receiver port and an ephemeral metrics port.
+			t.Parallel()
+
 			f, err := os.Open(tc.path)
 			if err != nil {
 				t.Fatalf("failed to open file %s: %v", tc.path, err)
@@ -80,3 +93,143 @@ func Test_Main(t *testing.T) {
 		})
 	}
 }
+
+func Test_verify(t *testing.T) {
+
+	tt := []struct {
+		name              string
+		deliveryGuarantee DeliveryGuarantee
+		receiverDisabled  bool
+		integrity         *IntegrityConfig
+		auth              *ReceiverAuthConfig
+		report            Report
+		wantErr           bool
+	}{
+		{
+			name:              "at least once with duplicates",
+			deliveryGuarantee: AtLeastOnce,
+			report:            Report{ReceivedCount: 10, DuplicateCount: 1, Metrics: Metrics{AcceptedCount: 10}},
+			wantErr:           false,
+		},
+		{
+			name:              "at least once with lost",
+			deliveryGuarantee: AtLeastOnce,
+			report:            Report{ReceivedCount: 9, LostCount: 1, Metrics: Metrics{AcceptedCount: 10}},
+			wantErr:           true,
+		},
+		{
+			name:              "at most once with lost",
+			deliveryGuarantee: AtMostOnce,
+			report:            Report{ReceivedCount: 9, LostCount: 1, Metrics: Metrics{AcceptedCount: 10}},
+			wantErr:           false,
+		},
+		{
+			name:              "at most once with duplicates",
+			deliveryGuarantee: AtMostOnce,
+			report:            Report{ReceivedCount: 10, DuplicateCount: 1, Metrics: Metrics{AcceptedCount: 10}},
+			wantErr:           true,
+		},
+		{
+			name:              "exactly once",
+			deliveryGuarantee: ExactlyOnce,
+			report:            Report{ReceivedCount: 10, Metrics: Metrics{AcceptedCount: 10}},
+			wantErr:           false,
+		},
+		{
+			name:              "exactly once with lost",
+			deliveryGuarantee: ExactlyOnce,
+			report:            Report{ReceivedCount: 9, LostCount: 1, Metrics: Metrics{AcceptedCount: 10}},
+			wantErr:           true,
+		},
+		{
+			name:              "exactly once with duplicates",
+			deliveryGuarantee: ExactlyOnce,
+			report:            Report{ReceivedCount: 10, DuplicateCount: 1, Metrics: Metrics{AcceptedCount: 10}},
+			wantErr:           true,
+		},
+		{
+			name:              "receiver disabled",
+			deliveryGuarantee: ExactlyOnce,
+			receiverDisabled:  true,
+			report:            Report{LostCount: 10, Metrics: Metrics{AcceptedCount: 10}},
+			wantErr:           false,
+		},
+		{
+			name:              "unknown events",
+			deliveryGuarantee: AtLeastOnce,
+			report:            Report{ReceivedCount: 10, UnknownCount: 1, Metrics: Metrics{AcceptedCount: 10}},
+			wantErr:           true,
+		},
+		{
+			name:              "at least once with rejected received",
+			deliveryGuarantee: AtLeastOnce,
+			report:            Report{ReceivedCount: 10, RejectedReceivedCount: 1, Metrics: Metrics{AcceptedCount: 10}},
+			wantErr:           false,
+		},
+		{
+			name:              "exactly once with rejected received",
+			deliveryGuarantee: ExactlyOnce,
+			report:            Report{ReceivedCount: 10, RejectedReceivedCount: 1, Metrics: Metrics{AcceptedCount: 10}},
+			wantErr:           true,
+		},
+		{
+			name:              "integrity violations unchecked",
+			deliveryGuarantee: AtLeastOnce,
+			integrity:         &IntegrityConfig{},
+			report:            Report{ReceivedCount: 10, IntegrityViolationCount: 1, Metrics: Metrics{AcceptedCount: 10}},
+			wantErr:           false,
+		},
+		{
+			name:              "too many integrity violations",
+			deliveryGuarantee: AtLeastOnce,
+			integrity:         &IntegrityConfig{MaxViolations: new(int)},
+			report:            Report{ReceivedCount: 10, IntegrityViolationCount: 1, Metrics: Metrics{AcceptedCount: 10}},
+			wantErr:           true,
+		},
+		{
+			name:              "at least once with lost replies",
+			deliveryGuarantee: AtLeastOnce,
+			report:            Report{ReceivedCount: 10, Replies: &ReplyReport{SentCount: 10, ReceivedCount: 9, LostCount: 1}, Metrics: Metrics{AcceptedCount: 10}},
+			wantErr:           true,
+		},
+		{
+			name:              "at most once with lost replies",
+			deliveryGuarantee: AtMostOnce,
+			report:            Report{ReceivedCount: 10, Replies: &ReplyReport{SentCount: 10, ReceivedCount: 9, LostCount: 1}, Metrics: Metrics{AcceptedCount: 10}},
+			wantErr:           false,
+		},
+		{
+			name:              "at least once with duplicate replies",
+			deliveryGuarantee: AtLeastOnce,
+			report:            Report{ReceivedCount: 10, Replies: &ReplyReport{SentCount: 10, ReceivedCount: 10, DuplicateCount: 1}, Metrics: Metrics{AcceptedCount: 10}},
+			wantErr:           false,
+		},
+		{
+			name:              "auth rejections unchecked",
+			deliveryGuarantee: AtLeastOnce,
+			auth:              &ReceiverAuthConfig{BearerToken: "token"},
+			report:            Report{ReceivedCount: 10, AuthRejectedCount: 1, Metrics: Metrics{AcceptedCount: 10}},
+			wantErr:           false,
+		},
+		{
+			name:              "too many auth rejections",
+			deliveryGuarantee: AtLeastOnce,
+			auth:              &ReceiverAuthConfig{BearerToken: "token", MaxRejectedEvents: new(int)},
+			report:            Report{ReceivedCount: 10, AuthRejectedCount: 1, Metrics: Metrics{AcceptedCount: 10}},
+			wantErr:           true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := verify(log.Default(), Config{
+				DeliveryGuarantee: tc.deliveryGuarantee,
+				Receiver:          ReceiverConfig{Disabled: tc.receiverDisabled, Auth: tc.auth},
+				Integrity:         tc.integrity,
+			}, tc.report)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("verify() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,323 @@
+package sacura
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/metric/instrument/asyncfloat64"
+	"go.opentelemetry.io/otel/metric/instrument/syncint64"
+	"go.opentelemetry.io/otel/metric/unit"
+	"go.opentelemetry.io/otel/sdk/metric/aggregator/histogram"
+	controller "go.opentelemetry.io/otel/sdk/metric/controller/basic"
+	"go.opentelemetry.io/otel/sdk/metric/export/aggregation"
+	processor "go.opentelemetry.io/otel/sdk/metric/processor/basic"
+	selector "go.opentelemetry.io/otel/sdk/metric/selector/simple"
+	"go.uber.org/atomic"
+)
+
+const (
+	DefaultMetricsAddress = ":9090"
+)
+
+var (
+	// DefaultHistogramBoundaries are the default histogram boundaries in milliseconds.
+	DefaultHistogramBoundaries = []float64{
+		10, 20, 50, 100, 500, 1000, // < 1s
+		5 * 1000, 10 * 1000, 30 * 1000, 60 * 1000, // < 60s
+		5 * 60 * 1000, 10 * 60 * 1000, 20 * 60 * 1000, // < 20m
+	}
+)
+
+// exportMetrics creates the meter of a run and, unless disabled, starts the metrics server exporting its instruments.
+//
+// Every run has its own meter provider, so that concurrent runs don't report each other's metrics.
+//
+// The metrics server is stopped and the metrics are logged when ctx is done, the returned wait function waits for
+// that to happen.
+func exportMetrics(ctx context.Context, logger *log.Logger, metricsConfig MetricsConfig) (metric.Meter, func(), error) {
+	boundaries := metricsConfig.HistogramBoundaries
+	if len(boundaries) == 0 {
+		boundaries = DefaultHistogramBoundaries
+	}
+	config := prometheus.Config{
+		DefaultHistogramBoundaries: boundaries,
+	}
+
+	ctrl := controller.New(
+		processor.NewFactory(
+			selector.NewWithHistogramDistribution(
+				histogram.WithExplicitBoundaries(config.DefaultHistogramBoundaries),
+			),
+			aggregation.CumulativeTemporalitySelector(),
+			processor.WithMemory(true),
+		),
+	)
+
+	promExporter, err := prometheus.New(config, ctrl)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create prometheus exporter: %w", err)
+	}
+
+	meter := ctrl.Meter("sacura")
+
+	if metricsConfig.Disabled {
+		logger.Println("Metrics server disabled")
+		return meter, func() {}, nil
+	}
+
+	address := metricsConfig.Address
+	if address == "" {
+		address = DefaultMetricsAddress
+	}
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to listen for metrics on %s: %w", address, err)
+	}
+	logger.Println("Metrics server listening on", ln.Addr())
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		s := http.Server{
+			Handler: http.HandlerFunc(promExporter.ServeHTTP),
+		}
+		defer s.Close()
+
+		go func() {
+			if err := s.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Println("Metrics server failed", err)
+			}
+		}()
+
+		<-ctx.Done()
+		scrapeMetrics(logger, ln.Addr())
+
+		logger.Println("Metrics server closed")
+	}()
+
+	return meter, wg.Wait, nil
+}
+
+// scrapeMetrics logs the metrics exposed by the metrics server listening on the given address.
+func scrapeMetrics(logger *log.Logger, addr net.Addr) {
+	host, port, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		logger.Println("failed to parse metrics address", addr, err)
+		return
+	}
+	if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() {
+		host = "localhost"
+	}
+
+	resp, err := http.DefaultClient.Get("http://" + net.JoinHostPort(host, port))
+	if err != nil {
+		logger.Println("failed to scrape metrics", err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		logger.Println("failed to scrape metrics, expected status code 2xx, got", resp.StatusCode)
+		return
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		logger.Println("failed to read metrics", err)
+		return
+	}
+	logger.Println("Metrics\n", string(body))
+}
+
+// receiverMetrics records metrics about the requests received by the receiver of a run.
+//
+// A nil receiverMetrics doesn't record anything.
+type receiverMetrics struct {
+	e2eLatencyHistogram        syncint64.Histogram
+	processingLatencyHistogram syncint64.Histogram
+	inFlightRequestsHistogram  syncint64.Histogram
+}
+
+func newReceiverMetrics(meter metric.Meter) (*receiverMetrics, error) {
+	m := &receiverMetrics{}
+
+	var err error
+	m.e2eLatencyHistogram, err = meter.SyncInt64().Histogram("latency_e2e_ms",
+		instrument.WithUnit(unit.Milliseconds),
+		instrument.WithDescription("Histogram for E2E latency since "+BenchmarkTimestampAttribute),
+	)
+	if err != nil {
+		return nil, err
+	}
+	m.processingLatencyHistogram, err = meter.SyncInt64().Histogram("processing_latency_ms",
+		instrument.WithUnit(unit.Milliseconds),
+		instrument.WithDescription("Histogram for processing latency"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	m.inFlightRequestsHistogram, err = meter.SyncInt64().Histogram("in_flight_requests",
+		instrument.WithUnit(unit.Milliseconds),
+		instrument.WithDescription("Histogram for in-flight requests"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *receiverMetrics) recordE2ELatency(ctx context.Context, ms int64, labels ...attribute.KeyValue) {
+	if m != nil {
+		m.e2eLatencyHistogram.Record(ctx, ms, labels...)
+	}
+}
+
+func (m *receiverMetrics) recordProcessingLatency(ctx context.Context, ms int64, labels ...attribute.KeyValue) {
+	if m != nil {
+		m.processingLatencyHistogram.Record(ctx, ms, labels...)
+	}
+}
+
+func (m *receiverMetrics) recordInFlightRequests(ctx context.Context, n int64, labels ...attribute.KeyValue) {
+	if m != nil {
+		m.inFlightRequestsHistogram.Record(ctx, n, labels...)
+	}
+}
+
+// senderMetrics records metrics about the requests sent by the sender.
+type senderMetrics struct {
+	latencyHistogram syncint64.Histogram
+	requestsCounter  syncint64.Counter
+	errorsCounter    syncint64.Counter
+	rateGauge        asyncfloat64.Gauge
+
+	requests *atomic.Int64
+}
+
+func newSenderMetrics(meter metric.Meter) (*senderMetrics, error) {
+	m := &senderMetrics{requests: atomic.NewInt64(0)}
+
+	var err error
+	m.latencyHistogram, err = meter.SyncInt64().Histogram("sender_latency_ms",
+		instrument.WithUnit(unit.Milliseconds),
+		instrument.WithDescription("Histogram for sender request latency"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	m.requestsCounter, err = meter.SyncInt64().Counter("sender_requests",
+		instrument.WithUnit(unit.Dimensionless),
+		instrument.WithDescription("Number of requests sent by status code"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	m.errorsCounter, err = meter.SyncInt64().Counter("sender_errors",
+		instrument.WithUnit(unit.Dimensionless),
+		instrument.WithDescription("Number of requests failed without a response"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	m.rateGauge, err = meter.AsyncFloat64().Gauge("sender_rate",
+		instrument.WithUnit(unit.Dimensionless),
+		instrument.WithDescription("Achieved requests per second since the previous collection"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var lock sync.Mutex
+	lastRequests, lastCollection := int64(0), time.Now()
+	err = meter.RegisterCallback([]instrument.Asynchronous{m.rateGauge}, func(ctx context.Context) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		now, requests := time.Now(), m.requests.Load()
+		if elapsed := now.Sub(lastCollection).Seconds(); elapsed > 0 {
+			m.rateGauge.Observe(ctx, float64(requests-lastRequests)/elapsed)
+		}
+		lastRequests, lastCollection = requests, now
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (m *senderMetrics) record(ctx context.Context, res *vegeta.Result) {
+	m.requests.Inc()
+
+	labels := []attribute.KeyValue{attribute.String("status_code", strconv.Itoa(int(res.Code)))}
+	m.latencyHistogram.Record(ctx, res.Latency.Milliseconds(), labels...)
+	m.requestsCounter.Add(ctx, 1, labels...)
+	if res.Error != "" {
+		m.errorsCounter.Add(ctx, 1)
+	}
+}
+
+// registerStateMetrics registers gauges reporting the progress of the run tracked by the given StateManager with the
+// meter of the run, they're released with the meter provider of the run.
+func registerStateMetrics(meter metric.Meter, s *StateManager) error {
+	accepted, err := meter.AsyncInt64().Gauge("state_accepted_events",
+		instrument.WithUnit(unit.Dimensionless),
+		instrument.WithDescription("Number of events accepted"),
+	)
+	if err != nil {
+		return err
+	}
+	received, err := meter.AsyncInt64().Gauge("state_received_events",
+		instrument.WithUnit(unit.Dimensionless),
+		instrument.WithDescription("Number of unique events received"),
+	)
+	if err != nil {
+		return err
+	}
+	outstanding, err := meter.AsyncInt64().Gauge("state_outstanding_events",
+		instrument.WithUnit(unit.Dimensionless),
+		instrument.WithDescription("Number of events accepted but not yet received"),
+	)
+	if err != nil {
+		return err
+	}
+	duplicates, err := meter.AsyncInt64().Gauge("state_duplicate_events",
+		instrument.WithUnit(unit.Dimensionless),
+		instrument.WithDescription("Number of duplicate events received"),
+	)
+	if err != nil {
+		return err
+	}
+	oldestOutstandingAge, err := meter.AsyncInt64().Gauge("state_oldest_outstanding_event_age_ms",
+		instrument.WithUnit(unit.Milliseconds),
+		instrument.WithDescription("Time since the oldest outstanding event has been accepted"),
+	)
+	if err != nil {
+		return err
+	}
+
+	return meter.RegisterCallback(
+		[]instrument.Asynchronous{accepted, received, outstanding, duplicates, oldestOutstandingAge},
+		func(ctx context.Context) {
+			p := s.Progress()
+			accepted.Observe(ctx, int64(p.AcceptedCount))
+			received.Observe(ctx, int64(p.ReceivedCount))
+			outstanding.Observe(ctx, int64(p.OutstandingCount))
+			duplicates.Observe(ctx, int64(p.DuplicateCount))
+			oldestOutstandingAge.Observe(ctx, p.OldestOutstandingAge.Milliseconds())
+		},
+	)
+}
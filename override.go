@@ -0,0 +1,205 @@
+package sacura
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-yaml/yaml"
+)
+
+// EnvOverridePrefix is the prefix of the environment variables overriding configuration fields.
+//
+// It's more specific than SACURA_, since Kubernetes sets variables like SACURA_SERVICE_HOST and SACURA_PORT for a
+// service named sacura.
+const EnvOverridePrefix = "SACURA_CONFIG_"
+
+// Override overrides a configuration field.
+type Override struct {
+	// Path are the keys of the field, struct fields are matched case-insensitively, map keys and slice indexes as is.
+	Path []string
+	// Value is the YAML value of the field.
+	Value string
+	// Origin is where the override comes from, it's used in errors and logs.
+	Origin string
+}
+
+func (o Override) String() string {
+	return fmt.Sprintf("%s from %s", strings.Join(o.Path, "."), o.Origin)
+}
+
+// ParseOverride parses an override in the form path=value where path is a dot separated list of keys, for example
+// sender.frequency=500.
+func ParseOverride(s string, origin string) (Override, error) {
+	i := strings.Index(s, "=")
+	if i <= 0 {
+		return Override{}, fmt.Errorf("invalid override %q from %s, expected <path>=<value>", s, origin)
+	}
+	return Override{Path: strings.Split(s[:i], "."), Value: s[i+1:], Origin: origin}, nil
+}
+
+// EnvOverrides returns the overrides of the environment variables with the EnvOverridePrefix in the given
+// environment, keys are separated by underscores, for example SACURA_CONFIG_SENDER_FREQUENCY=500.
+//
+// Keys are lowercased, since struct fields are matched case-insensitively, map keys are lowercase too.
+//
+// Overrides are sorted by variable name, so that they're applied in a stable order.
+func EnvOverrides(environ []string) []Override {
+	var overrides []Override
+	for _, kv := range environ {
+		if !strings.HasPrefix(kv, EnvOverridePrefix) {
+			continue
+		}
+		i := strings.Index(kv, "=")
+		if i < 0 {
+			continue
+		}
+		name := kv[:i]
+		overrides = append(overrides, Override{
+			Path:   strings.Split(strings.ToLower(strings.TrimPrefix(name, EnvOverridePrefix)), "_"),
+			Value:  kv[i+1:],
+			Origin: "environment variable " + name,
+		})
+	}
+	sort.SliceStable(overrides, func(i, j int) bool { return overrides[i].Origin < overrides[j].Origin })
+	return overrides
+}
+
+// applyOverrides applies the given overrides in order to the given YAML configuration, later overrides take
+// precedence.
+func applyOverrides(b []byte, overrides []Override) ([]byte, error) {
+	var root interface{}
+	if err := yaml.Unmarshal(b, &root); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal configuration: %w", err)
+	}
+
+	for _, o := range overrides {
+		path, containers, t, err := resolveOverridePath(reflect.TypeOf(Config{}), o.Path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid override %s: %w", o, err)
+		}
+
+		// Values are decoded into the field type, so that errors refer to the override and values are set as the
+		// field would decode them, for example yes is a string for string fields.
+		value := reflect.New(t)
+		if err := yaml.UnmarshalStrict([]byte(o.Value), value.Interface()); err != nil {
+			return nil, fmt.Errorf("invalid override %s: %w", o, err)
+		}
+
+		root, err = setOverride(root, path, containers, value.Elem().Interface())
+		if err != nil {
+			return nil, fmt.Errorf("invalid override %s: %w", o, err)
+		}
+	}
+
+	b, err := yaml.Marshal(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal overridden configuration: %w", err)
+	}
+	return b, nil
+}
+
+// resolveOverridePath resolves the given keys against t, it returns the keys with the struct field names of the
+// configuration, the kind of the container of each key and the type of the field.
+func resolveOverridePath(t reflect.Type, keys []string) ([]string, []reflect.Kind, reflect.Type, error) {
+	if len(keys) == 0 || (len(keys) == 1 && keys[0] == "") {
+		return nil, nil, nil, errors.New("empty path")
+	}
+
+	path := make([]string, 0, len(keys))
+	containers := make([]reflect.Kind, 0, len(keys))
+	for _, key := range keys {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Struct:
+			f, ok := structFieldByYAMLName(t, key)
+			if !ok {
+				return nil, nil, nil, fmt.Errorf("unknown field %q in %s", key, pathOrRoot(path))
+			}
+			path = append(path, strings.Split(f.Tag.Get("yaml"), ",")[0])
+			containers = append(containers, reflect.Map)
+			t = f.Type
+		case reflect.Map:
+			if key == "" {
+				return nil, nil, nil, fmt.Errorf("empty key in %s", pathOrRoot(path))
+			}
+			path = append(path, key)
+			containers = append(containers, reflect.Map)
+			t = t.Elem()
+		case reflect.Slice:
+			if i, err := strconv.Atoi(key); err != nil || i < 0 {
+				return nil, nil, nil, fmt.Errorf("invalid index %q in %s", key, pathOrRoot(path))
+			}
+			path = append(path, key)
+			containers = append(containers, reflect.Slice)
+			t = t.Elem()
+		default:
+			return nil, nil, nil, fmt.Errorf("%s has no field %q", pathOrRoot(path), key)
+		}
+	}
+	return path, containers, t, nil
+}
+
+func structFieldByYAMLName(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := strings.Split(f.Tag.Get("yaml"), ",")[0]
+		if f.PkgPath == "" && tag != "" && tag != "-" && strings.EqualFold(tag, name) {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func pathOrRoot(path []string) string {
+	if len(path) == 0 {
+		return "the configuration"
+	}
+	return strings.Join(path, ".")
+}
+
+// setOverride sets value at path in node, missing maps and slices are created.
+func setOverride(node interface{}, path []string, containers []reflect.Kind, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	if containers[0] == reflect.Slice {
+		s, ok := node.([]interface{})
+		if node != nil && !ok {
+			return nil, fmt.Errorf("%s isn't a list", path[0])
+		}
+		i, _ := strconv.Atoi(path[0])
+		if i > len(s) {
+			return nil, fmt.Errorf("index %d out of range, the list has %d elements", i, len(s))
+		}
+		if i == len(s) {
+			s = append(s, nil)
+		}
+		v, err := setOverride(s[i], path[1:], containers[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		s[i] = v
+		return s, nil
+	}
+
+	m, ok := node.(map[interface{}]interface{})
+	if node != nil && !ok {
+		return nil, fmt.Errorf("%s isn't a map", path[0])
+	}
+	if m == nil {
+		m = make(map[interface{}]interface{})
+	}
+	v, err := setOverride(m[path[0]], path[1:], containers[1:], value)
+	if err != nil {
+		return nil, err
+	}
+	m[path[0]] = v
+	return m, nil
+}
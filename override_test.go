@@ -0,0 +1,185 @@
+package sacura
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFileConfigOverrides(t *testing.T) {
+	const file = `
+sender:
+  target: http://localhost:8080
+  frequency: 100
+receiver:
+  port: 8080
+  timeout: 1m
+duration: 1m
+`
+
+	override := func(s string) Override {
+		o, err := ParseOverride(s, "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return o
+	}
+
+	tests := []struct {
+		name      string
+		overrides []Override
+		check     func(t *testing.T, config Config)
+		wantErr   string
+	}{
+		{
+			name:      "int field",
+			overrides: []Override{override("sender.frequency=500")},
+			check: func(t *testing.T, config Config) {
+				if config.Sender.FrequencyPerSecond != 500 {
+					t.Errorf("want frequency 500, got %d", config.Sender.FrequencyPerSecond)
+				}
+			},
+		},
+		{
+			name: "later overrides take precedence",
+			overrides: append(
+				EnvOverrides([]string{"SACURA_CONFIG_SENDER_FREQUENCY=200", "OTHER_SENDER_FREQUENCY=300"}),
+				override("sender.frequency=500"),
+			),
+			check: func(t *testing.T, config Config) {
+				if config.Sender.FrequencyPerSecond != 500 {
+					t.Errorf("want frequency 500, got %d", config.Sender.FrequencyPerSecond)
+				}
+			},
+		},
+		{
+			name:      "environment variable",
+			overrides: EnvOverrides([]string{"SACURA_CONFIG_RECEIVER_MAXDUPLICATESPERCENTAGE=5", "SACURA_CONFIG_DURATION=2m"}),
+			check: func(t *testing.T, config Config) {
+				if config.Receiver.MaxDuplicatesPercentage == nil || *config.Receiver.MaxDuplicatesPercentage != 5 {
+					t.Errorf("want maxDuplicatesPercentage 5, got %v", config.Receiver.MaxDuplicatesPercentage)
+				}
+				if config.ParsedDuration != 2*time.Minute {
+					t.Errorf("want duration 2m, got %v", config.ParsedDuration)
+				}
+			},
+		},
+		{
+			name: "kubernetes service links",
+			overrides: EnvOverrides([]string{
+				"SACURA_SERVICE_HOST=10.0.0.1",
+				"SACURA_SERVICE_PORT=8080",
+				"SACURA_PORT=tcp://10.0.0.1:8080",
+				"SACURA_PORT_8080_TCP=tcp://10.0.0.1:8080",
+				"SACURA_PORT_8080_TCP_PROTO=tcp",
+				"SACURA_PORT_8080_TCP_PORT=8080",
+				"SACURA_PORT_8080_TCP_ADDR=10.0.0.1",
+				"SACURA_RECEIVER_PORT=tcp://10.0.0.2:8080",
+			}),
+			check: func(t *testing.T, config Config) {
+				if config.Receiver.Port != 8080 {
+					t.Errorf("want receiver port 8080, got %d", config.Receiver.Port)
+				}
+			},
+		},
+		{
+			name:      "string field with a YAML boolean",
+			overrides: []Override{override("runId=yes")},
+			check: func(t *testing.T, config Config) {
+				if config.RunID != "yes" {
+					t.Errorf("want run ID yes, got %s", config.RunID)
+				}
+			},
+		},
+		{
+			name:      "map key",
+			overrides: []Override{override("sender.auth.headers.X-Api-Key=key")},
+			check: func(t *testing.T, config Config) {
+				want := map[string]string{"X-Api-Key": "key"}
+				if config.Sender.Auth == nil || !cmp.Equal(want, config.Sender.Auth.Headers) {
+					t.Errorf("want headers %v, got %+v", want, config.Sender.Auth)
+				}
+			},
+		},
+		{
+			name: "list element",
+			overrides: []Override{
+				override("scenario.0.duration=10s"),
+				override("scenario.1.duration=20s"),
+				override("scenario.1.frequency=50"),
+			},
+			check: func(t *testing.T, config Config) {
+				if len(config.Scenario) != 2 || config.Scenario[1].Frequency != 50 {
+					t.Errorf("want 2 phases, the second with frequency 50, got %+v", config.Scenario)
+				}
+				if config.ParsedDuration != 30*time.Second {
+					t.Errorf("want duration 30s, got %v", config.ParsedDuration)
+				}
+			},
+		},
+		{
+			name:      "whole struct",
+			overrides: []Override{override("receiver.reply={fraction: 0.5}")},
+			check: func(t *testing.T, config Config) {
+				if config.Receiver.Reply == nil || *config.Receiver.Reply.Fraction != 0.5 || config.Receiver.Reply.Path != DefaultReplyPath {
+					t.Errorf("want reply with fraction 0.5 and defaults, got %+v", config.Receiver.Reply)
+				}
+			},
+		},
+		{
+			name:      "unknown field",
+			overrides: EnvOverrides([]string{"SACURA_CONFIG_SENDER_FREQUNCY=500"}),
+			wantErr:   `unknown field "frequncy" in sender`,
+		},
+		{
+			name:      "invalid value",
+			overrides: []Override{override("sender.frequency=fast")},
+			wantErr:   "cannot unmarshal !!str `fast` into int",
+		},
+		{
+			name:      "index out of range",
+			overrides: []Override{override("scenario.1.duration=10s")},
+			wantErr:   "index 1 out of range",
+		},
+		{
+			name:      "validated after overrides",
+			overrides: []Override{override("sender.frequency=-1")},
+			wantErr:   "sender.frequency",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := FileConfig(strings.NewReader(file), tt.overrides...)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("want error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, config)
+		})
+	}
+}
+
+func TestParseOverride(t *testing.T) {
+	o, err := ParseOverride("sender.target=http://localhost:8080/?a=b", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Override{Path: []string{"sender", "target"}, Value: "http://localhost:8080/?a=b", Origin: "test"}
+	if diff := cmp.Diff(want, o); diff != "" {
+		t.Errorf("(-want, +got) %s", diff)
+	}
+
+	for _, s := range []string{"sender.target", "=1"} {
+		if _, err := ParseOverride(s, "test"); err == nil {
+			t.Errorf("want error for %q, got nil", s)
+		}
+	}
+}
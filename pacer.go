@@ -0,0 +1,181 @@
+package sacura
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+const (
+	// maxPaceLookahead is the maximum time we look ahead for the next hit, if no hit is expected in this time the
+	// attack is stopped.
+	maxPaceLookahead = 24 * time.Hour
+	// paceResolution is the precision used to find the time of the next hit.
+	paceResolution = time.Microsecond
+)
+
+// NewPacer creates a vegeta.Pacer for the given sender configuration.
+func NewPacer(config SenderConfig) (vegeta.Pacer, error) {
+	if f := config.Source.file(); f != nil && f.OriginalPace {
+		source, err := newFileSource(f)
+		if err != nil {
+			return nil, err
+		}
+		batchSize := 1
+		if config.Encoding == BatchEncoding {
+			batchSize = config.BatchSize
+		}
+		return source.pacer(batchSize), nil
+	}
+
+	p := config.Profile
+	switch {
+	case p == nil:
+		return vegeta.Rate{Freq: config.FrequencyPerSecond, Per: time.Second}, nil
+	case p.Linear != nil:
+		return rampPacer{From: float64(p.Linear.From), To: float64(p.Linear.To), Duration: p.Linear.ParsedDuration}, nil
+	case len(p.Steps) > 0:
+		return stepsPacer{Steps: p.Steps}, nil
+	case p.Sine != nil:
+		return vegeta.SinePacer{
+			Period:  p.Sine.ParsedPeriod,
+			Mean:    vegeta.Rate{Freq: p.Sine.Mean, Per: time.Second},
+			Amp:     vegeta.Rate{Freq: p.Sine.Amplitude, Per: time.Second},
+			StartAt: vegeta.MeanUp,
+		}, nil
+	case p.Burst != nil:
+		return burstPacer{Size: p.Burst.Size, Interval: p.Burst.ParsedInterval}, nil
+	}
+	return nil, fmt.Errorf("unknown profile %+v", *p)
+}
+
+// hitsCounter returns the number of hits expected to be sent during an attack lasting t.
+type hitsCounter interface {
+	hits(t time.Duration) float64
+}
+
+// paceHits implements vegeta.Pacer.Pace for pacers that know the expected number of hits at any point in time.
+//
+// It looks for the earliest time at which hits+1 hits are expected, so that it works for any non-decreasing number
+// of hits, including rates that are 0 for some time and bursts.
+func paceHits(p hitsCounter, elapsed time.Duration, hits uint64) (time.Duration, bool) {
+	next := float64(hits + 1)
+	if p.hits(elapsed) >= next {
+		// Running behind, send next hit immediately.
+		return 0, false
+	}
+
+	lo, hi := elapsed, elapsed+time.Second
+	for step := time.Second; p.hits(hi) < next; step *= 2 {
+		if hi-elapsed > maxPaceLookahead {
+			return 0, true
+		}
+		lo = hi
+		hi += step
+	}
+
+	for hi-lo > paceResolution {
+		mid := lo + (hi-lo)/2
+		if p.hits(mid) >= next {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+
+	return hi - elapsed, false
+}
+
+// rampPacer ramps the rate linearly from From to To hits per second over Duration, then it keeps sending at To hits
+// per second.
+type rampPacer struct {
+	From     float64
+	To       float64
+	Duration time.Duration
+}
+
+var _ vegeta.Pacer = rampPacer{}
+
+func (p rampPacer) Pace(elapsed time.Duration, hits uint64) (time.Duration, bool) {
+	return paceHits(p, elapsed, hits)
+}
+
+func (p rampPacer) Rate(elapsed time.Duration) float64 {
+	if elapsed >= p.Duration {
+		return p.To
+	}
+	return p.From + (p.To-p.From)*elapsed.Seconds()/p.Duration.Seconds()
+}
+
+func (p rampPacer) hits(t time.Duration) float64 {
+	if t <= 0 {
+		return 0
+	}
+	if t >= p.Duration {
+		return (p.From+p.To)*p.Duration.Seconds()/2 + p.To*(t-p.Duration).Seconds()
+	}
+	x := t.Seconds()
+	return p.From*x + (p.To-p.From)*math.Pow(x, 2)/(2*p.Duration.Seconds())
+}
+
+// stepsPacer sends hits at a constant rate for each step, after the last step it keeps sending at the rate of the
+// last step.
+type stepsPacer struct {
+	Steps []StepProfileConfig
+}
+
+var _ vegeta.Pacer = stepsPacer{}
+
+func (p stepsPacer) Pace(elapsed time.Duration, hits uint64) (time.Duration, bool) {
+	return paceHits(p, elapsed, hits)
+}
+
+func (p stepsPacer) Rate(elapsed time.Duration) float64 {
+	for _, s := range p.Steps {
+		if elapsed < s.ParsedDuration {
+			return float64(s.Frequency)
+		}
+		elapsed -= s.ParsedDuration
+	}
+	return float64(p.Steps[len(p.Steps)-1].Frequency)
+}
+
+func (p stepsPacer) hits(t time.Duration) float64 {
+	if t <= 0 {
+		return 0
+	}
+	hits := 0.0
+	for _, s := range p.Steps {
+		if t < s.ParsedDuration {
+			return hits + float64(s.Frequency)*t.Seconds()
+		}
+		hits += float64(s.Frequency) * s.ParsedDuration.Seconds()
+		t -= s.ParsedDuration
+	}
+	return hits + float64(p.Steps[len(p.Steps)-1].Frequency)*t.Seconds()
+}
+
+// burstPacer sends Size hits at once every Interval, starting immediately.
+type burstPacer struct {
+	Size     int
+	Interval time.Duration
+}
+
+var _ vegeta.Pacer = burstPacer{}
+
+func (p burstPacer) Pace(elapsed time.Duration, hits uint64) (time.Duration, bool) {
+	return paceHits(p, elapsed, hits)
+}
+
+func (p burstPacer) Rate(time.Duration) float64 {
+	return float64(p.Size) / p.Interval.Seconds()
+}
+
+func (p burstPacer) hits(t time.Duration) float64 {
+	if t < 0 {
+		return 0
+	}
+	return float64(p.Size) * float64(t/p.Interval+1)
+}
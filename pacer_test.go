@@ -0,0 +1,87 @@
+package sacura
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestNewPacer(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		config   SenderConfig
+		duration time.Duration
+		wantHits int
+	}{
+		{
+			name:     "constant",
+			config:   SenderConfig{FrequencyPerSecond: 100},
+			duration: 10 * time.Second,
+			wantHits: 1000,
+		},
+		{
+			name: "linear",
+			config: SenderConfig{Profile: &ProfileConfig{
+				Linear: &LinearProfileConfig{From: 0, To: 100, Duration: "10s", ParsedDuration: 10 * time.Second},
+			}},
+			duration: 20 * time.Second,
+			wantHits: 500 + 1000,
+		},
+		{
+			name: "steps",
+			config: SenderConfig{Profile: &ProfileConfig{
+				Steps: []StepProfileConfig{
+					{Duration: "5s", Frequency: 10, ParsedDuration: 5 * time.Second},
+					{Duration: "5s", Frequency: 0, ParsedDuration: 5 * time.Second},
+					{Duration: "5s", Frequency: 100, ParsedDuration: 5 * time.Second},
+				},
+			}},
+			duration: 20 * time.Second,
+			wantHits: 50 + 0 + 500 + 500,
+		},
+		{
+			name: "sine",
+			config: SenderConfig{Profile: &ProfileConfig{
+				Sine: &SineProfileConfig{Period: "10s", Mean: 100, Amplitude: 50, ParsedPeriod: 10 * time.Second},
+			}},
+			duration: 20 * time.Second,
+			wantHits: 2000,
+		},
+		{
+			name: "burst",
+			config: SenderConfig{Profile: &ProfileConfig{
+				Burst: &BurstProfileConfig{Size: 100, Interval: "5s", ParsedInterval: 5 * time.Second},
+			}},
+			duration: 19 * time.Second,
+			wantHits: 400,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewPacer(tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			elapsed, hits := time.Duration(0), uint64(0)
+			for {
+				wait, stop := p.Pace(elapsed, hits)
+				if stop {
+					break
+				}
+				elapsed += wait
+				if elapsed > tt.duration {
+					break
+				}
+				hits++
+			}
+
+			// Allow 1% error for rounding.
+			if diff := math.Abs(float64(hits) - float64(tt.wantHits)); diff > float64(tt.wantHits)/100 {
+				t.Errorf("want %d hits, got %d", tt.wantHits, hits)
+			}
+		})
+	}
+}
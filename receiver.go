@@ -2,14 +2,13 @@ package sacura
 
 import (
 	"context"
-	"errors"
+	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"math/rand"
 	"net/http"
 	"strconv"
-	"sync"
+	"strings"
 	"time"
 
 	ce "github.com/cloudevents/sdk-go/v2"
@@ -17,62 +16,84 @@ import (
 	"github.com/cloudevents/sdk-go/v2/event"
 	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/exporters/prometheus"
-	"go.opentelemetry.io/otel/metric/global"
-	"go.opentelemetry.io/otel/metric/instrument"
-	"go.opentelemetry.io/otel/metric/instrument/syncint64"
-	"go.opentelemetry.io/otel/metric/unit"
-	"go.opentelemetry.io/otel/sdk/metric/aggregator/histogram"
-	controller "go.opentelemetry.io/otel/sdk/metric/controller/basic"
-	"go.opentelemetry.io/otel/sdk/metric/export/aggregation"
-	processor "go.opentelemetry.io/otel/sdk/metric/processor/basic"
-	selector "go.opentelemetry.io/otel/sdk/metric/selector/simple"
+	"go.opentelemetry.io/otel/metric"
 	"go.uber.org/atomic"
 )
 
 var (
-	e2eLatencyHistogram       syncint64.Histogram  = nil
-	e2eLatencyHistogramLabels []attribute.KeyValue = nil
-
-	processingLatencyHistogram       syncint64.Histogram  = nil
+	e2eLatencyHistogramLabels        []attribute.KeyValue = nil
 	processingLatencyHistogramLabels []attribute.KeyValue = nil
-
-	inFlightRequestsHistogram       syncint64.Histogram  = nil
-	inFlightRequestsHistogramLabels []attribute.KeyValue = nil
+	inFlightRequestsHistogramLabels  []attribute.KeyValue = nil
 )
 
 const (
 	BenchmarkTimestampAttribute = "benchmarktimestamp"
 )
 
-func StartReceiver(ctx context.Context, config ReceiverConfig, received chan<- ce.Event) error {
+func StartReceiver(ctx context.Context, config ReceiverConfig, metricsConfig MetricsConfig, received chan<- ce.Event) error {
+	metricsCtx, stopMetrics := context.WithCancel(context.Background())
+	meter, wait, err := exportMetrics(metricsCtx, log.Default(), metricsConfig)
+	if err != nil {
+		stopMetrics()
+		close(received)
+		return err
+	}
+	defer func() {
+		stopMetrics()
+		wait()
+	}()
+
+	return startReceiverWithTimeout(ctx, log.Default(), meter, config, newExtendableTimeout(config.ParsedTimeout), received, nil)
+}
+
+// startReceiverWithTimeout starts the receiver, once ctx is done the receiver keeps receiving events until timeout
+// expires.
+//
+// Receiver metrics are recorded with meter, listener is notified of rejected requests and of replies, it can be nil.
+func startReceiverWithTimeout(ctx context.Context, logger *log.Logger, meter metric.Meter, config ReceiverConfig, timeout *extendableTimeout, received chan<- ce.Event, listener receiverListener) error {
 	defer close(received)
 
 	innerCtx, cancel := context.WithCancel(context.Background())
-	wait := exportMetrics(innerCtx)
-	defer wait()
+	defer cancel()
+
+	metrics, err := newReceiverMetrics(meter)
+	if err != nil {
+		return fmt.Errorf("failed to create receiver metrics: %w", err)
+	}
+
+	rec, err := newRecorder(logger, config.Record)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := rec.Close(); err != nil {
+			logger.Println(err)
+		}
+	}()
 
 	go func() {
 		defer cancel()
 
 		<-ctx.Done()
 		if err := ctx.Err(); err != nil {
-			log.Println(err)
+			logger.Println(err)
 		}
-		<-time.After(config.ParsedTimeout)
+		timeout.wait()
 
-		log.Println("Receiver timeout reached")
+		logger.Println("Receiver timeout reached")
 	}()
 
 	inFlightRequests := atomic.NewInt64(0)
 
-	err := startReceiver(innerCtx, &config, func(ctx context.Context, event *ce.Event, req *http.Request) error {
+	err = startReceiver(innerCtx, logger, &config, metrics, listener, func(ctx context.Context, event *ce.Event, req *http.Request) error {
+		rec.recordReceived(event, time.Now(), req)
+
 		inFlightRequests.Inc()
 		inFlightRequestsHistogramReqLabels := addRequestLabels(req, &config, inFlightRequestsHistogramLabels)
-		inFlightRequestsHistogram.Record(ctx, inFlightRequests.Load(), inFlightRequestsHistogramReqLabels...)
+		metrics.recordInFlightRequests(ctx, inFlightRequests.Load(), inFlightRequestsHistogramReqLabels...)
 		defer func() {
 			inFlightRequests.Dec()
-			inFlightRequestsHistogram.Record(ctx, inFlightRequests.Load(), inFlightRequestsHistogramReqLabels...)
+			metrics.recordInFlightRequests(ctx, inFlightRequests.Load(), inFlightRequestsHistogramReqLabels...)
 		}()
 
 		exstensions := event.Extensions()
@@ -84,13 +105,13 @@ func StartReceiver(ctx context.Context, config ReceiverConfig, received chan<- c
 			start := time.UnixMilli(t)
 			e2eLatency := time.Since(start)
 			if e2eLatency.Milliseconds() < 0 {
-				log.Printf("Negative e2e latency %d\n", e2eLatency.Milliseconds())
+				logger.Printf("Negative e2e latency %d\n", e2eLatency.Milliseconds())
 			} else {
-				e2eLatencyHistogram.Record(ctx, e2eLatency.Milliseconds(), addRequestLabels(req, &config, e2eLatencyHistogramLabels)...)
+				metrics.recordE2ELatency(ctx, e2eLatency.Milliseconds(), addRequestLabels(req, &config, e2eLatencyHistogramLabels)...)
 			}
 		}
 
-		maybeSleep(config)
+		maybeSleep(config.faultConfig(event), requestPath(req))
 		received <- *event
 
 		return nil
@@ -100,7 +121,7 @@ func StartReceiver(ctx context.Context, config ReceiverConfig, received chan<- c
 		case <-innerCtx.Done():
 			// There is not way ATM to know whether the receiver has been terminated because of the cancelled context or
 			// because there was an error, so if context is done suppress the error.
-			log.Println(err)
+			logger.Println(err)
 		default:
 			return fmt.Errorf("failed to start receiver: %w", err)
 		}
@@ -112,144 +133,112 @@ func StartReceiver(ctx context.Context, config ReceiverConfig, received chan<- c
 func addRequestLabels(req *http.Request, config *ReceiverConfig, latencyHistogramLabels []attribute.KeyValue) []attribute.KeyValue {
 	labels := make([]attribute.KeyValue, 0, len(latencyHistogramLabels)+2)
 	copy(labels, latencyHistogramLabels)
-	path := "/"
-	if req.URL.Path != "" {
-		path = req.URL.Path
-	}
-	labels = append(labels, attribute.String("request_path", path))
+	labels = append(labels, attribute.String("request_path", requestPath(req)))
 	if config.IncludeRemoteAddressLabel != nil && *config.IncludeRemoteAddressLabel {
 		labels = append(labels, attribute.String("remote_addr", req.RemoteAddr))
 	}
 	return labels
 }
 
-func maybeSleep(config ReceiverConfig) {
-	if config.ReceiverFaultConfig == nil || config.ReceiverFaultConfig.MinSleepDuration == nil {
-		return
+func requestPath(req *http.Request) string {
+	if req.URL.Path != "" {
+		return req.URL.Path
 	}
-
-	max := *config.ReceiverFaultConfig.MaxSleepDuration
-	min := *config.ReceiverFaultConfig.MinSleepDuration
-
-	time.Sleep(min + time.Duration(rand.Int63n(int64(max-min))))
+	return "/"
 }
 
-func exportMetrics(ctx context.Context) (wait func()) {
-	config := prometheus.Config{
-		DefaultHistogramBoundaries: []float64{
-			10, 20, 50, 100, 500, 1000, // < 1s
-			5 * 1000, 10 * 1000, 30 * 1000, 60 * 1000, // < 60s
-			5 * 60 * 1000, 10 * 60 * 1000, 20 * 60 * 1000, // < 20m
-		},
-	}
-
-	ctrl := controller.New(
-		processor.NewFactory(
-			selector.NewWithHistogramDistribution(
-				histogram.WithExplicitBoundaries(config.DefaultHistogramBoundaries),
-			),
-			aggregation.CumulativeTemporalitySelector(),
-			processor.WithMemory(true),
-		),
-	)
-
-	promExporter, err := prometheus.New(config, ctrl)
-	if err != nil {
-		panic(err)
-	}
-
-	global.SetMeterProvider(ctrl)
-
-	meter := global.Meter("sacura")
-
-	e2eLatencyHistogram, err = meter.SyncInt64().Histogram("latency_e2e_ms",
-		instrument.WithUnit(unit.Milliseconds),
-		instrument.WithDescription("Histogram for E2E latency since "+BenchmarkTimestampAttribute),
-	)
-	if err != nil {
-		panic(err)
-	}
-	processingLatencyHistogram, err = meter.SyncInt64().Histogram("processing_latency_ms",
-		instrument.WithUnit(unit.Milliseconds),
-		instrument.WithDescription("Histogram for processing latency"),
-	)
-	if err != nil {
-		panic(err)
-	}
-	inFlightRequestsHistogram, err = meter.SyncInt64().Histogram("in_flight_requests",
-		instrument.WithUnit(unit.Milliseconds),
-		instrument.WithDescription("Histogram for in-flight requests"),
-	)
-	if err != nil {
-		panic(err)
+func maybeSleep(config *ReceiverFaultConfig, path string) {
+	fault := config.forPath(path)
+	if fault == nil || fault.MinSleepDuration == nil {
+		return
 	}
 
-	var wg sync.WaitGroup
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-
-		s := http.Server{
-			Handler: http.HandlerFunc(promExporter.ServeHTTP),
-			Addr:    ":9090",
-		}
-		defer s.Close()
-
-		go func() {
-			if err := s.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-				log.Fatal(err)
-			}
-		}()
-
-		<-ctx.Done()
-		scrapeMetrics()
+	max := *fault.MaxSleepDuration
+	min := *fault.MinSleepDuration
 
-		log.Println("Metrics server closed")
-	}()
-
-	return wg.Wait
+	time.Sleep(min + time.Duration(rand.Int63n(int64(max-min))))
 }
 
-func scrapeMetrics() {
-	resp, err := http.DefaultClient.Get("http://localhost:9090")
+// startReceiver starts an HTTP server calling h with every received event, replies received on any path are passed
+// to listener instead, and they're never replied to.
+func startReceiver(ctx context.Context, logger *log.Logger, config *ReceiverConfig, metrics *receiverMetrics, listener receiverListener, h func(context.Context, *event.Event, *http.Request) error) error {
+	verifier, err := newTokenVerifier(config.Auth)
 	if err != nil {
-		panic(err)
-	}
-	if resp.StatusCode >= 300 {
-		panic("expected status code 2xx, got " + fmt.Sprint(resp.StatusCode))
+		return err
 	}
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		panic(err)
+	if listener == nil {
+		listener = nopReceiverListener{}
 	}
-	log.Println("Metrics\n", string(body))
-}
 
-func startReceiver(ctx context.Context, config *ReceiverConfig, h func(context.Context, *event.Event, *http.Request) error) error {
 	s := http.Server{
 		Addr: fmt.Sprintf(":%d", config.Port),
 		Handler: http.HandlerFunc(func(writer http.ResponseWriter, r *http.Request) {
 			start := time.Now()
 			defer func() {
-				processingLatencyHistogram.Record(ctx, time.Since(start).Milliseconds(), addRequestLabels(r, config, processingLatencyHistogramLabels)...)
+				metrics.recordProcessingLatency(ctx, time.Since(start).Milliseconds(), addRequestLabels(r, config, processingLatencyHistogramLabels)...)
 			}()
 
-			msg := cehttp.NewMessageFromHttpRequest(r)
-			e, err := binding.ToEvent(ctx, msg)
+			events, err := readEvents(ctx, r)
 			if err != nil {
 				http.Error(writer, err.Error(), http.StatusBadRequest)
 				return
 			}
-			if err := h(ctx, e, r); err != nil {
-				http.Error(writer, err.Error(), http.StatusInternalServerError)
+			if reason := verifier.verify(r); reason != "" {
+				// Events are not handled, so that they aren't considered received.
+				listener.authRejected(reason, events)
+				writer.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(writer, fmt.Sprintf("unauthorized: %s", reason), http.StatusUnauthorized)
 				return
 			}
+			var first *ce.Event
+			if len(events) > 0 {
+				first = &events[0]
+			}
+			// Events in the same request are sent in the same phase.
+			if f := pickFault(config.faultConfig(first), requestPath(r)); f != nil {
+				// Events are not handled, so that they aren't considered received.
+				f.respond(logger, writer)
+				return
+			}
+			for i := range events {
+				if isReply(&events[i]) {
+					listener.replyReceived(events[i], requestPath(r))
+					continue
+				}
+				if err := h(ctx, &events[i], r); err != nil {
+					http.Error(writer, err.Error(), http.StatusInternalServerError)
+					return
+				}
+			}
+			if len(events) == 1 && !isBatch(r) {
+				if reply := config.Reply.reply(first); reply != nil {
+					// The reply is tracked before it's sent, so that it's known when it comes back.
+					listener.replySent(*reply)
+					if err := writeReply(ctx, writer, reply); err != nil {
+						logger.Println("failed to write reply", err)
+					}
+					return
+				}
+			}
 			writer.WriteHeader(http.StatusOK)
 		}),
 	}
 
+	if config.TLS != nil {
+		tlsConfig, err := config.TLS.tlsConfig()
+		if err != nil {
+			return err
+		}
+		s.TLSConfig = tlsConfig
+	}
+
 	errChan := make(chan error, 1)
 	go func() {
+		if s.TLSConfig != nil {
+			// Certificates are in the TLS configuration.
+			errChan <- s.ListenAndServeTLS("", "")
+			return
+		}
 		errChan <- s.ListenAndServe()
 	}()
 
@@ -260,3 +249,25 @@ func startReceiver(ctx context.Context, config *ReceiverConfig, h func(context.C
 		return err
 	}
 }
+
+// readEvents reads the events in the given request, the request can be in binary, structured or batched content mode.
+func readEvents(ctx context.Context, r *http.Request) ([]ce.Event, error) {
+	if isBatch(r) {
+		var events []ce.Event
+		if err := json.NewDecoder(r.Body).Decode(&events); err != nil {
+			return nil, fmt.Errorf("failed to decode batch: %w", err)
+		}
+		return events, nil
+	}
+
+	msg := cehttp.NewMessageFromHttpRequest(r)
+	e, err := binding.ToEvent(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+	return []ce.Event{*e}, nil
+}
+
+func isBatch(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get(cehttp.ContentType), ce.ApplicationCloudEventsBatchJSON)
+}
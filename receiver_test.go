@@ -19,7 +19,7 @@ func TestStartReceiverContextCancelled(t *testing.T) {
 		<-time.After(time.Second)
 	}()
 
-	err := StartReceiver(ctx, ReceiverConfig{Port: 9201}, received)
+	err := StartReceiver(ctx, ReceiverConfig{Port: 9201}, MetricsConfig{Address: ":0"}, received)
 
 	if err != nil {
 		t.Fatal("expected nil, got", err)
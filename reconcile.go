@@ -0,0 +1,212 @@
+package sacura
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// State is the state of a run exported by a sender-only or a receiver-only instance.
+//
+// The states of the sender and of every receiver of a run are reconciled into one report with Reconcile.
+type State struct {
+	RunID string `json:"runId,omitempty"`
+
+	// Sent collects accepted events by partition key, it's only set by the sender.
+	Sent map[string][]string `json:"sent,omitempty"`
+	// Metrics are the sender metrics, they're only set by the sender.
+	Metrics *Metrics `json:"metrics,omitempty"`
+	// RejectedIDs are the IDs of the events rejected by the system under test, they're only set by the sender.
+	RejectedIDs []string `json:"rejectedIds,omitempty"`
+	// UncertainIDs are the IDs of the events sent in requests that failed without a response, they're only set by the
+	// sender.
+	UncertainIDs []string `json:"uncertainIds,omitempty"`
+	// SentByPhase collects accepted events by scenario phase, it's only set by the sender.
+	SentByPhase map[string][]string `json:"sentByPhase,omitempty"`
+	// Phases are the sender metrics of each scenario phase, they're only set by the sender.
+	Phases []PhaseMetrics `json:"phases,omitempty"`
+	// SentByTarget collects accepted events by target, it's only set by the sender.
+	SentByTarget map[string][]string `json:"sentByTarget,omitempty"`
+	// Targets are the sender metrics of each target, they're only set by the sender.
+	Targets []TargetMetrics `json:"targets,omitempty"`
+
+	// Received collects received events by partition key, including duplicates.
+	Received map[string][]string `json:"received,omitempty"`
+	// OtherRuns collects the number of events received from other runs by run ID.
+	OtherRuns map[string]int `json:"otherRuns,omitempty"`
+	// AuthRejections collects the number of events rejected by the receiver authentication by reason.
+	AuthRejections map[AuthRejection]int `json:"authRejections,omitempty"`
+	// RepliesSent are the IDs of the reply events sent by the receiver.
+	RepliesSent []string `json:"repliesSent,omitempty"`
+	// RepliesReceived are the IDs of the received reply events, including duplicates.
+	RepliesReceived []string `json:"repliesReceived,omitempty"`
+	// MisroutedReplies are the IDs of the reply events received on a path other than the reply path.
+	MisroutedReplies []string `json:"misroutedReplies,omitempty"`
+	// IntegrityViolations collects the IDs of the events failing the integrity verification by kind of violation.
+	IntegrityViolations     map[IntegrityViolation][]string `json:"integrityViolations,omitempty"`
+	IntegrityViolationCount int                             `json:"integrityViolationCount,omitempty"`
+}
+
+// ExportState exports the state of the run, it should be called once the run is terminated.
+func (s *StateManager) ExportState() State {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	state := State{
+		RunID:                   s.config.RunID,
+		Received:                copyStringSliceMap(s.received),
+		IntegrityViolationCount: s.integrityViolationCount,
+	}
+	if len(s.otherRuns) > 0 {
+		state.OtherRuns = make(map[string]int, len(s.otherRuns))
+		for k, v := range s.otherRuns {
+			state.OtherRuns[k] = v
+		}
+	}
+	if len(s.authRejections) > 0 {
+		state.AuthRejections = make(map[AuthRejection]int, len(s.authRejections))
+		for k, v := range s.authRejections {
+			state.AuthRejections[k] = v
+		}
+	}
+	if s.repliesSent.Len() > 0 {
+		state.RepliesSent = s.repliesSent.List()
+	}
+	state.RepliesReceived = append([]string(nil), s.repliesReceived...)
+	state.MisroutedReplies = append([]string(nil), s.misroutedReplies...)
+	if len(s.integrityViolations) > 0 {
+		state.IntegrityViolations = make(map[IntegrityViolation][]string, len(s.integrityViolations))
+		for k, v := range s.integrityViolations {
+			state.IntegrityViolations[k] = append([]string(nil), v...)
+		}
+	}
+	if !s.config.Sender.Disabled {
+		metrics := s.metrics
+		state.Sent = copyStringSliceMap(s.sent)
+		state.Metrics = &metrics
+		state.RejectedIDs = s.metrics.RejectedIDs
+		state.UncertainIDs = s.metrics.UncertainIDs
+		if len(s.sentByPhase) > 0 {
+			state.SentByPhase = copyStringSliceMap(s.sentByPhase)
+		}
+		state.Phases = s.metrics.Phases
+		if len(s.sentByTarget) > 0 {
+			state.SentByTarget = copyStringSliceMap(s.sentByTarget)
+		}
+		state.Targets = s.metrics.Targets
+	}
+	return state
+}
+
+// importState merges the given state into the state manager.
+func (s *StateManager) importState(state State) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for k, v := range state.Sent {
+		s.sent[k] = append(s.sent[k], v...)
+	}
+	for k, v := range state.SentByPhase {
+		s.sentByPhase[k] = append(s.sentByPhase[k], v...)
+	}
+	for k, v := range state.SentByTarget {
+		s.sentByTarget[k] = append(s.sentByTarget[k], v...)
+	}
+	for k, v := range state.Received {
+		s.received[k] = append(s.received[k], v...)
+	}
+	for k, v := range state.OtherRuns {
+		s.otherRuns[k] += v
+	}
+	for k, v := range state.AuthRejections {
+		s.authRejections[k] += v
+	}
+	s.repliesSent.Insert(state.RepliesSent...)
+	s.repliesReceived = append(s.repliesReceived, state.RepliesReceived...)
+	s.misroutedReplies = append(s.misroutedReplies, state.MisroutedReplies...)
+	for k, v := range state.IntegrityViolations {
+		s.integrityViolations[k] = append(s.integrityViolations[k], v...)
+	}
+	s.integrityViolationCount += state.IntegrityViolationCount
+}
+
+// Reconcile reconciles the states exported by the sender and the receivers of a run into one report.
+//
+// Exactly one state must come from the sender. When events are ordered, every partition key must be received by a
+// single receiver, since the order of events received by different receivers isn't known.
+func Reconcile(config Config, states []State) (Report, error) {
+	var sender *State
+	for i := range states {
+		if states[i].Metrics == nil {
+			continue
+		}
+		if sender != nil {
+			return Report{}, errors.New("more than one sender state, only one sender per run is supported")
+		}
+		sender = &states[i]
+	}
+	if sender == nil {
+		return Report{}, errors.New("no sender state")
+	}
+	for _, state := range states {
+		if state.RunID != sender.RunID {
+			return Report{}, fmt.Errorf("state of run %q can't be reconciled with state of run %q", state.RunID, sender.RunID)
+		}
+	}
+
+	config = reconciledConfig(config)
+	config.RunID = sender.RunID
+
+	sm := NewStateManager(config)
+	for _, state := range states {
+		sm.importState(state)
+	}
+	metrics := *sender.Metrics
+	metrics.RejectedIDs = sender.RejectedIDs
+	metrics.UncertainIDs = sender.UncertainIDs
+	metrics.Phases = sender.Phases
+	metrics.Targets = sender.Targets
+	sm.Terminated(metrics)
+
+	return sm.GenerateReport(), nil
+}
+
+// reconciledConfig returns the configuration of a run made of a sender-only instance and receiver-only instances.
+func reconciledConfig(config Config) Config {
+	config.Sender.Disabled = false
+	config.Receiver.Disabled = false
+	return config
+}
+
+// WriteState writes the given state to the given file.
+func WriteState(path string, state State) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create state file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	if err := json.NewEncoder(f).Encode(state); err != nil {
+		return fmt.Errorf("failed to write state file %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// ReadState reads a state written by WriteState.
+func ReadState(r io.Reader) (State, error) {
+	state := State{}
+	if err := json.NewDecoder(r).Decode(&state); err != nil {
+		return State{}, fmt.Errorf("failed to decode state: %w", err)
+	}
+	return state, nil
+}
+
+func copyStringSliceMap(m map[string][]string) map[string][]string {
+	c := make(map[string][]string, len(m))
+	for k, v := range m {
+		c[k] = append([]string(nil), v...)
+	}
+	return c
+}
@@ -0,0 +1,132 @@
+package sacura
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReconcile(t *testing.T) {
+
+	sender := State{
+		RunID:        "run",
+		Sent:         map[string][]string{unknownPartitionKey: {"1", "2", "3", "4"}},
+		Metrics:      &Metrics{ProposedCount: 6, AcceptedCount: 4, RejectedCount: 1, UncertainCount: 1},
+		RejectedIDs:  []string{"5"},
+		UncertainIDs: []string{"6"},
+	}
+
+	tests := []struct {
+		name           string
+		states         []State
+		wantLost       []string
+		wantDuplicates []string
+		wantRejected   []string
+		wantUncertain  []string
+		wantOtherRuns  int
+		wantErr        bool
+	}{
+		{
+			name: "receivers",
+			states: []State{
+				sender,
+				{RunID: "run", Received: map[string][]string{unknownPartitionKey: {"1", "2"}}},
+				{RunID: "run", Received: map[string][]string{unknownPartitionKey: {"2", "5", "6"}}, OtherRuns: map[string]int{"stale": 1}},
+			},
+			wantLost:       []string{"3", "4"},
+			wantDuplicates: []string{"2"},
+			wantRejected:   []string{"5"},
+			wantUncertain:  []string{"6"},
+			wantOtherRuns:  1,
+		},
+		{
+			name: "no sender",
+			states: []State{
+				{RunID: "run", Received: map[string][]string{unknownPartitionKey: {"1"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "multiple senders",
+			states:  []State{sender, sender},
+			wantErr: true,
+		},
+		{
+			name: "different runs",
+			states: []State{
+				sender,
+				{RunID: "other", Received: map[string][]string{unknownPartitionKey: {"1"}}},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := Reconcile(Config{}, tt.states)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Reconcile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if report.RunID != "run" {
+				t.Errorf("want run ID %q, got %q", "run", report.RunID)
+			}
+			if diff := cmp.Diff(tt.wantLost, report.LostEventsByPartitionKey[unknownPartitionKey]); diff != "" {
+				t.Errorf("lost events (-want, +got) %s", diff)
+			}
+			if diff := cmp.Diff(tt.wantDuplicates, report.DuplicateEventsByPartitionKey[unknownPartitionKey]); diff != "" {
+				t.Errorf("duplicate events (-want, +got) %s", diff)
+			}
+			if diff := cmp.Diff(tt.wantRejected, report.RejectedReceivedEventsByPartitionKey[unknownPartitionKey]); diff != "" {
+				t.Errorf("rejected received events (-want, +got) %s", diff)
+			}
+			if diff := cmp.Diff(tt.wantUncertain, report.UncertainReceivedEventsByPartitionKey[unknownPartitionKey]); diff != "" {
+				t.Errorf("uncertain received events (-want, +got) %s", diff)
+			}
+			if report.UnknownCount != 0 {
+				t.Errorf("want no unknown events, got %v", report.UnknownEventsByPartitionKey)
+			}
+			if report.OtherRunsCount != tt.wantOtherRuns {
+				t.Errorf("want %d events from other runs, got %d", tt.wantOtherRuns, report.OtherRunsCount)
+			}
+		})
+	}
+}
+
+func TestWriteReadState(t *testing.T) {
+	want := State{
+		RunID:    "run",
+		Sent:     map[string][]string{"0": {"1", "2"}},
+		Metrics:  &Metrics{ProposedCount: 2, AcceptedCount: 2},
+		Received: map[string][]string{"0": {"1"}},
+	}
+
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := WriteState(path, want); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	got, err := ReadState(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want.Sent, got.Sent); diff != "" {
+		t.Errorf("sent (-want, +got) %s", diff)
+	}
+	if diff := cmp.Diff(want.Received, got.Received); diff != "" {
+		t.Errorf("received (-want, +got) %s", diff)
+	}
+	if got.RunID != want.RunID || got.Metrics == nil || got.Metrics.AcceptedCount != want.Metrics.AcceptedCount {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+}
@@ -0,0 +1,212 @@
+package sacura
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	ce "github.com/cloudevents/sdk-go/v2"
+)
+
+// RecordedEvent is an event recorded to a file.
+type RecordedEvent struct {
+	ID           string `json:"id"`
+	PartitionKey string `json:"partitionKey,omitempty"`
+	// Time is the time the event has been received or accepted.
+	Time time.Time `json:"time"`
+	// Target is the target the event has been sent to, it's only recorded with multiple targets.
+	Target string `json:"target,omitempty"`
+
+	// RemoteAddress, Path and Headers are only recorded for received events.
+	RemoteAddress string      `json:"remoteAddress,omitempty"`
+	Path          string      `json:"path,omitempty"`
+	Headers       http.Header `json:"headers,omitempty"`
+
+	// Data is only recorded when RecordConfig.IncludeData is true.
+	Data []byte `json:"data,omitempty"`
+}
+
+// recordFlushInterval is how often recorded events are flushed to the file, so that the file is up to date while the
+// run is in progress.
+const recordFlushInterval = time.Second
+
+// recorder writes recorded events to a file, one JSON object per line.
+//
+// A nil recorder discards every event.
+type recorder struct {
+	logger *log.Logger
+	config *RecordConfig
+
+	lock    sync.Mutex
+	file    *os.File
+	gz      *gzip.Writer
+	buf     *bufio.Writer
+	encoder *json.Encoder
+
+	done    chan struct{}
+	flushWg sync.WaitGroup
+}
+
+// newRecorder creates a recorder for the given configuration, it returns a nil recorder when config is nil.
+//
+// Errors happening in the background are logged with logger.
+func newRecorder(logger *log.Logger, config *RecordConfig) (*recorder, error) {
+	if config == nil {
+		return nil, nil
+	}
+
+	f, err := os.Create(config.File)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create record file %s: %w", config.File, err)
+	}
+
+	r := &recorder{logger: logger, config: config, file: f, done: make(chan struct{})}
+	var w io.Writer = f
+	if config.Compression == GzipCompression {
+		r.gz = gzip.NewWriter(f)
+		w = r.gz
+	}
+	r.buf = bufio.NewWriter(w)
+	r.encoder = json.NewEncoder(r.buf)
+
+	r.flushWg.Add(1)
+	go r.flushPeriodically()
+
+	return r, nil
+}
+
+// flushPeriodically flushes the recorded events every recordFlushInterval until the recorder is closed.
+func (r *recorder) flushPeriodically() {
+	defer r.flushWg.Done()
+
+	ticker := time.NewTicker(recordFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.lock.Lock()
+			if err := r.flush(); err != nil {
+				r.logger.Println(err)
+			}
+			r.lock.Unlock()
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// flush writes the buffered events to the file, it must be called with the lock held.
+func (r *recorder) flush() error {
+	if err := r.buf.Flush(); err != nil {
+		return fmt.Errorf("failed to flush record file %s: %w", r.config.File, err)
+	}
+	if r.gz != nil {
+		if err := r.gz.Flush(); err != nil {
+			return fmt.Errorf("failed to flush record file %s: %w", r.config.File, err)
+		}
+	}
+	return nil
+}
+
+// recordReceived records an event received at the given time with the given request.
+func (r *recorder) recordReceived(e *ce.Event, t time.Time, req *http.Request) {
+	if r == nil {
+		return
+	}
+	re := r.newRecordedEvent(e, t)
+	re.RemoteAddress = req.RemoteAddr
+	re.Path = requestPath(req)
+	re.Headers = redactHeaders(req.Header, r.config.ParsedRedactedHeaders)
+	r.record(re)
+}
+
+// redactedHeaders are the headers carrying credentials, their values aren't recorded.
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization"}
+
+// redactHeaders returns a copy of the given headers where the values of redactedHeaders and of the given canonical
+// header names are replaced.
+func redactHeaders(h http.Header, names []string) http.Header {
+	c := h.Clone()
+	for _, names := range [][]string{redactedHeaders, names} {
+		for _, name := range names {
+			if _, ok := c[name]; ok {
+				c[name] = []string{redacted}
+			}
+		}
+	}
+	return c
+}
+
+// recordAccepted records an event accepted at the given time.
+func (r *recorder) recordAccepted(e *ce.Event, t time.Time) {
+	if r == nil {
+		return
+	}
+	r.record(r.newRecordedEvent(e, t))
+}
+
+func (r *recorder) newRecordedEvent(e *ce.Event, t time.Time) RecordedEvent {
+	re := RecordedEvent{
+		ID:           e.ID(),
+		PartitionKey: partitionKey(e),
+		Time:         t,
+		Target:       eventTarget(e),
+	}
+	if r.config.IncludeData {
+		re.Data = e.Data()
+	}
+	return re
+}
+
+// partitionKey returns the partition key of the given event or an empty string if it doesn't have one.
+func partitionKey(e *ce.Event) string {
+	if v, ok := e.Extensions()["partitionkey"]; ok {
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+func (r *recorder) record(re RecordedEvent) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if err := r.encoder.Encode(re); err != nil {
+		// Recording is best effort, it shouldn't fail the run.
+		r.logger.Println("failed to record event", re.ID, "to", r.config.File, err)
+	}
+}
+
+// Close flushes the recorded events and closes the file.
+func (r *recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+
+	close(r.done)
+	r.flushWg.Wait()
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if err := r.buf.Flush(); err != nil {
+		_ = r.file.Close()
+		return fmt.Errorf("failed to flush record file %s: %w", r.config.File, err)
+	}
+	if r.gz != nil {
+		if err := r.gz.Close(); err != nil {
+			_ = r.file.Close()
+			return fmt.Errorf("failed to close record file %s: %w", r.config.File, err)
+		}
+	}
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("failed to close record file %s: %w", r.config.File, err)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package sacura
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	ce "github.com/cloudevents/sdk-go/v2"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRecorder(t *testing.T) {
+
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		config RecordConfig
+		want   []RecordedEvent
+	}{
+		{
+			name:   "no compression",
+			config: RecordConfig{Compression: NoCompression},
+			want: []RecordedEvent{
+				{ID: "1", PartitionKey: "0", Time: now},
+				{ID: "2", Time: now, RemoteAddress: "127.0.0.1:1234", Path: "/path", Headers: http.Header{
+					"Ce-Id":               []string{"2"},
+					"X-Api-Key":           []string{"key"},
+					"Authorization":       []string{redacted},
+					"Proxy-Authorization": []string{redacted},
+				}},
+			},
+		},
+		{
+			name:   "gzip with data and auth headers",
+			config: RecordConfig{Compression: GzipCompression, IncludeData: true, ParsedRedactedHeaders: []string{"X-Api-Key"}},
+			want: []RecordedEvent{
+				{ID: "1", PartitionKey: "0", Time: now, Data: []byte(`{"n":1}`)},
+				{ID: "2", Time: now, RemoteAddress: "127.0.0.1:1234", Path: "/path", Headers: http.Header{
+					"Ce-Id":               []string{"2"},
+					"X-Api-Key":           []string{redacted},
+					"Authorization":       []string{redacted},
+					"Proxy-Authorization": []string{redacted},
+				}, Data: []byte(`{"n":2}`)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.File = filepath.Join(t.TempDir(), "events.jsonl")
+
+			r, err := newRecorder(log.Default(), &tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			accepted := ce.NewEvent()
+			accepted.SetID("1")
+			accepted.SetExtension("partitionkey", "0")
+			_ = accepted.SetData("application/json", map[string]int{"n": 1})
+			r.recordAccepted(&accepted, now)
+
+			received := ce.NewEvent()
+			received.SetID("2")
+			_ = received.SetData("application/json", map[string]int{"n": 2})
+			req, _ := http.NewRequest(http.MethodPost, "http://localhost/path", nil)
+			req.RemoteAddr = "127.0.0.1:1234"
+			req.Header.Set("Ce-Id", "2")
+			req.Header.Set("Authorization", "Bearer token")
+			req.Header.Set("Proxy-Authorization", "Basic dXNlcjpwYXNz")
+			req.Header.Set("X-Api-Key", "key")
+			r.recordReceived(&received, now, req)
+
+			if got := req.Header.Get("Authorization"); got != "Bearer token" {
+				t.Errorf("want request headers not to be modified, got Authorization %q", got)
+			}
+
+			if err := r.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			f, err := os.Open(tt.config.File)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			var in io.Reader = f
+			if tt.config.Compression == GzipCompression {
+				gz, err := gzip.NewReader(f)
+				if err != nil {
+					t.Fatal(err)
+				}
+				in = gz
+			}
+
+			var got []RecordedEvent
+			scanner := bufio.NewScanner(in)
+			for scanner.Scan() {
+				var re RecordedEvent
+				if err := json.Unmarshal(scanner.Bytes(), &re); err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, re)
+			}
+			if err := scanner.Err(); err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("(-want, +got) %s", diff)
+			}
+		})
+	}
+}
+
+func TestRecorderNil(t *testing.T) {
+	r, err := newRecorder(log.Default(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := ce.NewEvent()
+	r.recordAccepted(&e, time.Now())
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
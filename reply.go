@@ -0,0 +1,85 @@
+package sacura
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"net/http"
+
+	ce "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/binding"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+)
+
+const (
+	// ReplyToExtension is the extension holding the ID of the event a reply event has been sent for.
+	ReplyToExtension = "sacurareplyto"
+	// ReplyIDSuffix is appended to the ID of an event to derive the ID of its reply.
+	ReplyIDSuffix = "-reply"
+
+	DefaultReplyType   = "sacura.reply"
+	DefaultReplySource = "sacura"
+	DefaultReplyPath   = "/reply"
+)
+
+// receiverListener is notified of the requests and the events the receiver handles besides the received events.
+type receiverListener interface {
+	// authRejected is called with the events of every request rejected by the receiver authentication.
+	authRejected(reason AuthRejection, events []ce.Event)
+	// replySent is called with every reply before it's sent.
+	replySent(reply ce.Event)
+	// replyReceived is called with every reply received on the given path.
+	replyReceived(reply ce.Event, path string)
+}
+
+type nopReceiverListener struct{}
+
+func (nopReceiverListener) authRejected(AuthRejection, []ce.Event) {}
+func (nopReceiverListener) replySent(ce.Event)                     {}
+func (nopReceiverListener) replyReceived(ce.Event, string)         {}
+
+// reply returns the reply to the given event, it returns nil when the event isn't replied to.
+//
+// A nil ReplyConfig doesn't reply to any event.
+func (r *ReplyConfig) reply(e *ce.Event) *ce.Event {
+	if r == nil || isReply(e) || !replied(e.ID(), *r.Fraction) {
+		return nil
+	}
+
+	reply := ce.NewEvent()
+	reply.SetID(e.ID() + ReplyIDSuffix)
+	reply.SetType(r.Type)
+	reply.SetSource(r.Source)
+	reply.SetTime(e.Time())
+	reply.SetExtension(ReplyToExtension, e.ID())
+	if v, ok := e.Extensions()[RunIDExtension]; ok {
+		reply.SetExtension(RunIDExtension, v)
+	}
+	if v, ok := e.Extensions()[PhaseExtension]; ok {
+		reply.SetExtension(PhaseExtension, v)
+	}
+	_ = reply.SetData(ce.ApplicationJSON, map[string]string{"replyTo": e.ID()})
+	return &reply
+}
+
+// replied returns whether the event with the given ID is replied to, the result only depends on id, so that
+// redeliveries of an event get the same reply.
+func replied(id string, fraction float64) bool {
+	if fraction >= 1 {
+		return true
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(id))
+	return float64(h.Sum32()) < fraction*math.MaxUint32
+}
+
+// isReply returns whether the given event is a reply sent by a receiver.
+func isReply(e *ce.Event) bool {
+	_, ok := e.Extensions()[ReplyToExtension]
+	return ok
+}
+
+// writeReply writes the given reply as response in binary mode.
+func writeReply(ctx context.Context, w http.ResponseWriter, reply *ce.Event) error {
+	return cehttp.WriteResponseWriter(ctx, binding.ToMessage(reply), http.StatusOK, w)
+}
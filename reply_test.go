@@ -0,0 +1,204 @@
+package sacura
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	ce "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/binding"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	cetest "github.com/cloudevents/sdk-go/v2/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestReplyConfigReply(t *testing.T) {
+	one, zero := 1.0, 0.0
+	config := &ReplyConfig{Fraction: &one, Type: "reply.type", Source: "reply/source", Path: DefaultReplyPath}
+
+	e := cetest.FullEvent()
+	e.SetExtension(RunIDExtension, "run")
+	e.SetExtension(PhaseExtension, "phase")
+
+	reply := config.reply(&e)
+	if reply == nil {
+		t.Fatal("want reply, got nil")
+	}
+	if reply.ID() != e.ID()+ReplyIDSuffix || reply.Type() != "reply.type" || reply.Source() != "reply/source" {
+		t.Errorf("unexpected reply attributes %s", reply.String())
+	}
+	want := map[string]interface{}{
+		ReplyToExtension: e.ID(),
+		RunIDExtension:   "run",
+		PhaseExtension:   "phase",
+	}
+	if diff := cmp.Diff(want, reply.Extensions()); diff != "" {
+		t.Errorf("(-want, +got) %s", diff)
+	}
+	if err := reply.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	if r := config.reply(reply); r != nil {
+		t.Errorf("want no reply to a reply, got %s", r.String())
+	}
+	if r := (&ReplyConfig{Fraction: &zero}).reply(&e); r != nil {
+		t.Errorf("want no reply with fraction 0, got %s", r.String())
+	}
+	if r := (*ReplyConfig)(nil).reply(&e); r != nil {
+		t.Errorf("want no reply without reply configuration, got %s", r.String())
+	}
+}
+
+func TestReplied(t *testing.T) {
+	const n = 10000
+	count := 0
+	for i := 0; i < n; i++ {
+		id := fmt.Sprint(i)
+		r := replied(id, 0.3)
+		if r != replied(id, 0.3) {
+			t.Fatalf("want the same result for the same ID %s", id)
+		}
+		if r {
+			count++
+		}
+	}
+	if count < n*25/100 || count > n*35/100 {
+		t.Errorf("want about 30%% of events replied to, got %d out of %d", count, n)
+	}
+}
+
+func TestReceiverReply(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	one := 1.0
+	config := &ReceiverConfig{
+		Port:  9204,
+		Reply: &ReplyConfig{Fraction: &one, Type: DefaultReplyType, Source: DefaultReplySource, Path: DefaultReplyPath},
+	}
+
+	sm := NewStateManager(Config{Receiver: *config})
+	received := make(chan ce.Event, 10)
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- startReceiver(ctx, log.Default(), config, nil, sm, func(_ context.Context, e *ce.Event, _ *http.Request) error {
+			received <- *e
+			return nil
+		})
+	}()
+
+	e := cetest.FullEvent()
+	resp := postEvent(t, "http://localhost:9204", e)
+	reply, err := binding.ToEvent(ctx, cehttp.NewMessageFromHttpResponse(resp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := <-received; got.ID() != e.ID() {
+		t.Errorf("want event %s, got %s", e.ID(), got.ID())
+	}
+	if reply.ID() != e.ID()+ReplyIDSuffix {
+		t.Errorf("want reply %s, got %s", e.ID()+ReplyIDSuffix, reply.ID())
+	}
+
+	// Replies are acknowledged without a reply and they aren't passed to the handler.
+	resp = postEvent(t, "http://localhost:9204"+DefaultReplyPath, *reply)
+	if resp.Header.Get("Ce-Id") != "" {
+		t.Errorf("want no reply to a reply, got %s", resp.Header.Get("Ce-Id"))
+	}
+	resp.Body.Close()
+
+	want := &ReplyReport{SentCount: 1, ReceivedCount: 1}
+	if diff := cmp.Diff(want, sm.replyReport(), cmpopts.EquateEmpty()); diff != "" {
+		t.Errorf("(-want, +got) %s", diff)
+	}
+	select {
+	case got := <-received:
+		t.Errorf("want no received event, got %s", got.ID())
+	default:
+	}
+
+	cancel()
+	if err := <-errChan; err != nil {
+		t.Error(err)
+	}
+}
+
+func postEvent(t *testing.T, url string, e ce.Event) *http.Response {
+	t.Helper()
+
+	hdr, body, err := encode(BinaryEncoding, []ce.Event{e})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(string(body)))
+		req.Header = hdr
+		resp, err := http.DefaultClient.Do(req)
+		if err == nil {
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("want status code %d, got %d", http.StatusOK, resp.StatusCode)
+			}
+			return resp
+		}
+		if !isConnectionRefused(err) {
+			t.Fatal(err)
+		}
+		// The receiver might not be started yet.
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatal("receiver not started")
+	return nil
+}
+
+func TestStateManagerReplies(t *testing.T) {
+	sm := NewStateManager(Config{
+		RunID:    "run",
+		Receiver: ReceiverConfig{Reply: &ReplyConfig{Path: DefaultReplyPath}},
+	})
+
+	reply := func(id string, runID string) ce.Event {
+		e := ce.NewEvent()
+		e.SetID(id)
+		e.SetExtension(ReplyToExtension, strings.TrimSuffix(id, ReplyIDSuffix))
+		e.SetExtension(RunIDExtension, runID)
+		return e
+	}
+
+	for _, id := range []string{"a-reply", "b-reply", "c-reply", "d-reply"} {
+		sm.replySent(reply(id, "run"))
+	}
+	sm.replyReceived(reply("a-reply", "run"), DefaultReplyPath)
+	sm.replyReceived(reply("a-reply", "run"), DefaultReplyPath)
+	sm.replyReceived(reply("b-reply", "run"), "/")
+	sm.replyReceived(reply("c-reply", "run"), DefaultReplyPath)
+	sm.replyReceived(reply("e-reply", "run"), DefaultReplyPath)
+	sm.replyReceived(reply("f-reply", "other"), DefaultReplyPath)
+
+	sm.Terminated(Metrics{})
+	report := sm.GenerateReport()
+
+	want := &ReplyReport{
+		SentCount:      4,
+		ReceivedCount:  4,
+		LostCount:      1,
+		DuplicateCount: 1,
+		MisroutedCount: 1,
+		UnknownCount:   1,
+		Lost:           []string{"d-reply"},
+		Duplicates:     []string{"a-reply"},
+		Misrouted:      []string{"b-reply"},
+		Unknown:        []string{"e-reply"},
+	}
+	if diff := cmp.Diff(want, report.Replies, cmpopts.EquateEmpty()); diff != "" {
+		t.Errorf("(-want, +got) %s", diff)
+	}
+	if report.OtherRunsCount != 1 {
+		t.Errorf("want 1 event from other runs, got %d", report.OtherRunsCount)
+	}
+}
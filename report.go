@@ -1,13 +1,113 @@
 package sacura
 
 import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/go-yaml/yaml"
 	vegeta "github.com/tsenart/vegeta/v12/lib"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+type ReportFormat string
+
+const (
+	JSONReportFormat     ReportFormat = "json"
+	YAMLReportFormat     ReportFormat = "yaml"
+	MarkdownReportFormat ReportFormat = "markdown"
+	JUnitReportFormat    ReportFormat = "junit"
 )
 
+// ParseReportFormat parses the given report format, an empty format is JSONReportFormat.
+func ParseReportFormat(format string) (ReportFormat, error) {
+	switch f := ReportFormat(format); f {
+	case "":
+		return JSONReportFormat, nil
+	case JSONReportFormat, YAMLReportFormat, MarkdownReportFormat, JUnitReportFormat:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown report format %q, supported values are %q, %q, %q and %q",
+		format, JSONReportFormat, YAMLReportFormat, MarkdownReportFormat, JUnitReportFormat)
+}
+
 type Metrics struct {
-	ProposedCount int            `json:"proposedCount"`
-	AcceptedCount int            `json:"acceptedCount"`
-	Metrics       vegeta.Metrics `json:"metrics"`
+	ProposedCount int `json:"proposedCount"`
+	AcceptedCount int `json:"acceptedCount"`
+	// RejectedCount is the number of events sent in requests that got a non-2xx response.
+	RejectedCount int `json:"rejectedCount"`
+	// RejectedIDs are the IDs of the events sent in requests that got a non-2xx response.
+	RejectedIDs []string `json:"-"`
+	// UncertainCount is the number of events sent in requests that failed without a response, for example because
+	// of a timeout, the system under test might have accepted them or not.
+	UncertainCount int `json:"uncertainCount"`
+	// UncertainIDs are the IDs of the events sent in requests that failed without a response.
+	UncertainIDs []string       `json:"-"`
+	Metrics      vegeta.Metrics `json:"metrics"`
+	// Phases are the metrics of each scenario phase, Metrics are the metrics of the whole scenario.
+	Phases []PhaseMetrics `json:"-"`
+	// Targets are the metrics of each target when events are sent to multiple targets.
+	Targets []TargetMetrics `json:"-"`
+}
+
+// TargetMetrics are the sender metrics of a target, the proposed count of a target is the number of events sent to
+// the target.
+type TargetMetrics struct {
+	Target  string  `json:"target"`
+	Metrics Metrics `json:"metrics"`
+}
+
+// TargetReport is the report of a target.
+type TargetReport struct {
+	Target string `json:"target"`
+	// ReceivedCount is the number of unique events sent to the target that have been received.
+	ReceivedCount int `json:"receivedCount"`
+	// LostCount is the number of events accepted by the target that haven't been received.
+	LostCount int `json:"lostCount"`
+	// DuplicateCount is the number of duplicates of events sent to the target.
+	DuplicateCount int     `json:"duplicateCount"`
+	Metrics        Metrics `json:"metrics"`
+}
+
+// ReplyReport is the report of the reply events sent by the receiver.
+type ReplyReport struct {
+	// SentCount is the number of unique replies sent by the receiver.
+	SentCount int `json:"sentCount"`
+	// ReceivedCount is the number of unique replies received.
+	ReceivedCount int `json:"receivedCount"`
+	// LostCount is the number of replies sent but not received.
+	LostCount int `json:"lostCount"`
+	// DuplicateCount is the number of duplicate replies received.
+	DuplicateCount int `json:"duplicateCount"`
+	// MisroutedCount is the number of replies received on a path other than the reply path, including duplicates.
+	MisroutedCount int `json:"misroutedCount"`
+	// UnknownCount is the number of replies received but never sent.
+	UnknownCount int      `json:"unknownCount"`
+	Lost         []string `json:"lost,omitempty"`
+	Duplicates   []string `json:"duplicates,omitempty"`
+	Misrouted    []string `json:"misrouted,omitempty"`
+	Unknown      []string `json:"unknown,omitempty"`
+}
+
+// PhaseMetrics are the sender metrics of a scenario phase.
+type PhaseMetrics struct {
+	Name    string  `json:"name"`
+	Metrics Metrics `json:"metrics"`
+}
+
+// PhaseReport is the report of a scenario phase.
+type PhaseReport struct {
+	Name string `json:"name"`
+	// ReceivedCount is the number of unique events sent in the phase that have been received.
+	ReceivedCount int `json:"receivedCount"`
+	// LostCount is the number of events accepted in the phase that haven't been received.
+	LostCount int `json:"lostCount"`
+	// DuplicateCount is the number of duplicates of events sent in the phase.
+	DuplicateCount int     `json:"duplicateCount"`
+	Metrics        Metrics `json:"metrics"`
 }
 
 type Report struct {
@@ -20,6 +120,326 @@ type Report struct {
 	ReceivedCount int `json:"receivedCount"`
 	// ReceivedEventsByPartitionKey collects all events by partition, including duplicates
 	ReceivedEventsByPartitionKey map[string][]string `json:"-"`
-	Terminated                   bool                `json:"terminated"`
-	Metrics                      Metrics             `json:"metrics"`
+	// OrderingViolationCount is the number of events received out of order across all partitions.
+	OrderingViolationCount int `json:"orderingViolationCount"`
+	// OrderingViolationsByPartitionKey collects ordering violations by partition key.
+	OrderingViolationsByPartitionKey map[string]PartitionOrderingViolations `json:"orderingViolations,omitempty"`
+	// RejectedReceivedCount is the number of unique events received that were sent but rejected with a non-2xx
+	// response.
+	RejectedReceivedCount int `json:"rejectedReceivedCount"`
+	// RejectedReceivedEventsByPartitionKey collects events received that were rejected by partition key.
+	RejectedReceivedEventsByPartitionKey map[string][]string `json:"rejectedReceivedEvents,omitempty"`
+	// UncertainReceivedCount is the number of unique events received that were sent in requests that failed without
+	// a response, they might have been accepted, so they aren't unknown.
+	UncertainReceivedCount int `json:"uncertainReceivedCount"`
+	// UncertainReceivedEventsByPartitionKey collects received events that were sent in requests that failed without a
+	// response by partition key.
+	UncertainReceivedEventsByPartitionKey map[string][]string `json:"uncertainReceivedEvents,omitempty"`
+	// UnknownCount is the number of unique events received that were never sent.
+	UnknownCount int `json:"unknownCount"`
+	// UnknownEventsByPartitionKey collects events received that were never sent by partition key.
+	UnknownEventsByPartitionKey map[string][]string `json:"unknownEvents,omitempty"`
+	// OtherRunsCount is the number of events received from other runs, they're excluded from every other count.
+	OtherRunsCount int `json:"otherRunsCount"`
+	// OtherRunsEventCounts collects the number of events received from other runs by run ID.
+	OtherRunsEventCounts map[string]int `json:"otherRuns,omitempty"`
+	// AuthRejectedCount is the number of events in requests rejected by the receiver authentication, they're
+	// excluded from the received events.
+	AuthRejectedCount int `json:"authRejectedCount"`
+	// AuthRejections collects the number of events rejected by the receiver authentication by reason.
+	AuthRejections map[AuthRejection]int `json:"authRejections,omitempty"`
+	// IntegrityViolationCount is the number of received events failing the integrity verification, including
+	// duplicates.
+	IntegrityViolationCount int `json:"integrityViolationCount"`
+	// IntegrityViolations collects the IDs of the events failing the integrity verification by kind of violation.
+	IntegrityViolations map[IntegrityViolation][]string `json:"integrityViolations,omitempty"`
+	// Phases are the reports of each scenario phase, every other count is the total of the scenario.
+	Phases []PhaseReport `json:"phases,omitempty"`
+	// Targets are the reports of each target when events are sent to multiple targets.
+	Targets []TargetReport `json:"targets,omitempty"`
+	// Replies is the report of the reply events, it's only set when the receiver replies to events.
+	Replies    *ReplyReport `json:"replies,omitempty"`
+	Terminated bool         `json:"terminated"`
+	Metrics    Metrics      `json:"metrics"`
+	// RunID is the ID of the run.
+	RunID string `json:"runId,omitempty"`
+	// DeliveryGuarantee is the delivery guarantee the report has been verified against.
+	DeliveryGuarantee DeliveryGuarantee `json:"deliveryGuarantee"`
+	Verdict           Verdict           `json:"verdict"`
+}
+
+// Verdict is the outcome of verifying a Report.
+type Verdict struct {
+	Passed bool `json:"passed"`
+	// Reason is the reason of the failure, it's empty when Passed is true.
+	Reason string `json:"reason,omitempty"`
+}
+
+func newVerdict(err error) Verdict {
+	if err != nil {
+		return Verdict{Passed: false, Reason: err.Error()}
+	}
+	return Verdict{Passed: true}
+}
+
+type PartitionOrderingViolations struct {
+	Count      int                 `json:"count"`
+	Violations []OrderingViolation `json:"violations"`
+}
+
+// OrderingViolation describes an event received out of order, at a position where a different event was expected.
+type OrderingViolation struct {
+	// Position is the index in the received sequence of the partition, duplicates and lost events excluded.
+	Position   int    `json:"position"`
+	ExpectedID string `json:"expectedId"`
+	ActualID   string `json:"actualId"`
+}
+
+// WriteReport writes the report to w in the given format.
+func WriteReport(w io.Writer, format ReportFormat, report Report) error {
+	switch format {
+	case JSONReportFormat, "":
+		b, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal report: %w", err)
+		}
+		_, err = w.Write(b)
+		return err
+	case YAMLReportFormat:
+		return writeYAMLReport(w, report)
+	case MarkdownReportFormat:
+		return writeMarkdownReport(w, report)
+	case JUnitReportFormat:
+		return writeJUnitReport(w, report)
+	}
+	return fmt.Errorf("unknown report format %q", format)
+}
+
+func writeYAMLReport(w io.Writer, report Report) error {
+	b, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+	// JSON is valid YAML, unmarshalling into a yaml.MapSlice keeps the fields order and the JSON field names.
+	m := yaml.MapSlice{}
+	if err := yaml.Unmarshal(b, &m); err != nil {
+		return fmt.Errorf("failed to convert report to YAML: %w", err)
+	}
+	b, err = yaml.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+func writeMarkdownReport(w io.Writer, report Report) error {
+	var b strings.Builder
+
+	verdict := "PASSED"
+	if !report.Verdict.Passed {
+		verdict = "FAILED"
+	}
+	fmt.Fprintf(&b, "# Sacura report\n\n")
+	fmt.Fprintf(&b, "**Verdict**: %s\n\n", verdict)
+	if report.Verdict.Reason != "" {
+		fmt.Fprintf(&b, "```\n%s\n```\n\n", report.Verdict.Reason)
+	}
+
+	fmt.Fprintf(&b, "| Metric | Value |\n|---|---|\n")
+	if report.RunID != "" {
+		fmt.Fprintf(&b, "| Run ID | %s |\n", report.RunID)
+	}
+	fmt.Fprintf(&b, "| Delivery guarantee | %s |\n", report.DeliveryGuarantee)
+	fmt.Fprintf(&b, "| Proposed | %d |\n", report.Metrics.ProposedCount)
+	fmt.Fprintf(&b, "| Accepted | %d |\n", report.Metrics.AcceptedCount)
+	fmt.Fprintf(&b, "| Received | %d |\n", report.ReceivedCount)
+	fmt.Fprintf(&b, "| Lost | %d |\n", report.LostCount)
+	fmt.Fprintf(&b, "| Duplicates | %d |\n", report.DuplicateCount)
+	fmt.Fprintf(&b, "| Ordering violations | %d |\n", report.OrderingViolationCount)
+	fmt.Fprintf(&b, "| Rejected but received | %d |\n", report.RejectedReceivedCount)
+	fmt.Fprintf(&b, "| Uncertain but received | %d |\n", report.UncertainReceivedCount)
+	fmt.Fprintf(&b, "| Unknown | %d |\n", report.UnknownCount)
+	fmt.Fprintf(&b, "| Integrity violations | %d |\n", report.IntegrityViolationCount)
+	fmt.Fprintf(&b, "| Rejected by receiver authentication | %d |\n", report.AuthRejectedCount)
+	fmt.Fprintf(&b, "| From other runs | %d |\n", report.OtherRunsCount)
+	fmt.Fprintf(&b, "| Requests | %d |\n", report.Metrics.Metrics.Requests)
+	fmt.Fprintf(&b, "| Rate | %.2f/s |\n", report.Metrics.Metrics.Rate)
+	fmt.Fprintf(&b, "| Success | %.2f%% |\n", report.Metrics.Metrics.Success*100)
+
+	l := report.Metrics.Metrics.Latencies
+	fmt.Fprintf(&b, "\n## Request latencies\n\n")
+	fmt.Fprintf(&b, "| Mean | P50 | P90 | P95 | P99 | Max |\n|---|---|---|---|---|---|\n")
+	fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s |\n", l.Mean, l.P50, l.P90, l.P95, l.P99, l.Max)
+
+	if len(report.Metrics.Metrics.StatusCodes) > 0 {
+		fmt.Fprintf(&b, "\n## Status codes\n\n| Status code | Count |\n|---|---|\n")
+		for _, code := range sets.StringKeySet(report.Metrics.Metrics.StatusCodes).List() {
+			fmt.Fprintf(&b, "| %s | %d |\n", code, report.Metrics.Metrics.StatusCodes[code])
+		}
+	}
+
+	if len(report.Phases) > 0 {
+		fmt.Fprintf(&b, "\n## Phases\n\n")
+		fmt.Fprintf(&b, "| Phase | Accepted | Received | Lost | Duplicates | Rate | Mean latency | P99 latency |\n|---|---|---|---|---|---|---|---|\n")
+		for _, p := range report.Phases {
+			l := p.Metrics.Metrics.Latencies
+			fmt.Fprintf(&b, "| %s | %d | %d | %d | %d | %.2f/s | %s | %s |\n",
+				p.Name, p.Metrics.AcceptedCount, p.ReceivedCount, p.LostCount, p.DuplicateCount, p.Metrics.Metrics.Rate, l.Mean, l.P99)
+		}
+	}
+
+	if len(report.Targets) > 0 {
+		fmt.Fprintf(&b, "\n## Targets\n\n")
+		fmt.Fprintf(&b, "| Target | Accepted | Received | Lost | Duplicates | Success | Mean latency | P99 latency |\n|---|---|---|---|---|---|---|---|\n")
+		for _, t := range report.Targets {
+			l := t.Metrics.Metrics.Latencies
+			fmt.Fprintf(&b, "| %s | %d | %d | %d | %d | %.2f%% | %s | %s |\n",
+				t.Target, t.Metrics.AcceptedCount, t.ReceivedCount, t.LostCount, t.DuplicateCount, t.Metrics.Metrics.Success*100, l.Mean, l.P99)
+		}
+	}
+
+	if r := report.Replies; r != nil {
+		fmt.Fprintf(&b, "\n## Replies\n\n")
+		fmt.Fprintf(&b, "| Sent | Received | Lost | Duplicates | Misrouted | Unknown |\n|---|---|---|---|---|---|\n")
+		fmt.Fprintf(&b, "| %d | %d | %d | %d | %d | %d |\n", r.SentCount, r.ReceivedCount, r.LostCount, r.DuplicateCount, r.MisroutedCount, r.UnknownCount)
+		for _, ids := range []struct {
+			name string
+			ids  []string
+		}{{"Lost", r.Lost}, {"Duplicate", r.Duplicates}, {"Misrouted", r.Misrouted}, {"Unknown", r.Unknown}} {
+			if len(ids.ids) > 0 {
+				fmt.Fprintf(&b, "\n%s replies: %s\n", ids.name, strings.Join(ids.ids, ", "))
+			}
+		}
+	}
+
+	if len(report.LostEventsByPartitionKey) > 0 {
+		fmt.Fprintf(&b, "\n## Lost events\n\n| Partition key | Events |\n|---|---|\n")
+		for _, pk := range sets.StringKeySet(report.LostEventsByPartitionKey).List() {
+			fmt.Fprintf(&b, "| %s | %s |\n", pk, strings.Join(report.LostEventsByPartitionKey[pk], ", "))
+		}
+	}
+
+	if len(report.RejectedReceivedEventsByPartitionKey) > 0 {
+		fmt.Fprintf(&b, "\n## Rejected but received events\n\n| Partition key | Events |\n|---|---|\n")
+		for _, pk := range sets.StringKeySet(report.RejectedReceivedEventsByPartitionKey).List() {
+			fmt.Fprintf(&b, "| %s | %s |\n", pk, strings.Join(report.RejectedReceivedEventsByPartitionKey[pk], ", "))
+		}
+	}
+
+	if len(report.UncertainReceivedEventsByPartitionKey) > 0 {
+		fmt.Fprintf(&b, "\n## Uncertain but received events\n\n| Partition key | Events |\n|---|---|\n")
+		for _, pk := range sets.StringKeySet(report.UncertainReceivedEventsByPartitionKey).List() {
+			fmt.Fprintf(&b, "| %s | %s |\n", pk, strings.Join(report.UncertainReceivedEventsByPartitionKey[pk], ", "))
+		}
+	}
+
+	if len(report.UnknownEventsByPartitionKey) > 0 {
+		fmt.Fprintf(&b, "\n## Unknown events\n\n| Partition key | Events |\n|---|---|\n")
+		for _, pk := range sets.StringKeySet(report.UnknownEventsByPartitionKey).List() {
+			fmt.Fprintf(&b, "| %s | %s |\n", pk, strings.Join(report.UnknownEventsByPartitionKey[pk], ", "))
+		}
+	}
+
+	if len(report.AuthRejections) > 0 {
+		fmt.Fprintf(&b, "\n## Receiver authentication rejections\n\n| Reason | Events |\n|---|---|\n")
+		reasons := make([]string, 0, len(report.AuthRejections))
+		for reason := range report.AuthRejections {
+			reasons = append(reasons, string(reason))
+		}
+		sort.Strings(reasons)
+		for _, reason := range reasons {
+			fmt.Fprintf(&b, "| %s | %d |\n", reason, report.AuthRejections[AuthRejection(reason)])
+		}
+	}
+
+	if len(report.IntegrityViolations) > 0 {
+		fmt.Fprintf(&b, "\n## Integrity violations\n\n| Violation | Events |\n|---|---|\n")
+		for _, v := range []IntegrityViolation{MissingExtensionsViolation, CorruptedDataViolation, MutatedAttributesViolation} {
+			if ids, ok := report.IntegrityViolations[v]; ok {
+				fmt.Fprintf(&b, "| %s | %s |\n", v, strings.Join(ids, ", "))
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+type junitTestSuites struct {
+	XMLName    xml.Name         `xml:"testsuites"`
+	TestSuites []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Time      float64         `xml:"time,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Content string `xml:",chardata"`
+}
+
+func writeJUnitReport(w io.Writer, report Report) error {
+	jsonReport, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+
+	duration := report.Metrics.Metrics.Duration.Seconds()
+	tc := junitTestCase{
+		ClassName: "sacura",
+		Name:      fmt.Sprintf("delivery %s", report.DeliveryGuarantee),
+		Time:      duration,
+		SystemOut: string(jsonReport),
+	}
+	failures := 0
+	if !report.Verdict.Passed {
+		failures = 1
+		tc.Failure = &junitFailure{
+			Message: firstLine(report.Verdict.Reason),
+			Type:    "VerificationFailed",
+			Content: report.Verdict.Reason,
+		}
+	}
+
+	suites := junitTestSuites{
+		TestSuites: []junitTestSuite{{
+			Name:      "sacura",
+			Tests:     1,
+			Failures:  failures,
+			Time:      duration,
+			TestCases: []junitTestCase{tc},
+		}},
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+	return enc.Flush()
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
 }
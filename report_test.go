@@ -0,0 +1,102 @@
+package sacura
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/go-yaml/yaml"
+)
+
+func TestWriteReport(t *testing.T) {
+
+	report := Report{
+		LostCount:                1,
+		LostEventsByPartitionKey: map[string][]string{unknownPartitionKey: {"1"}},
+		ReceivedCount:            9,
+		Metrics:                  Metrics{ProposedCount: 10, AcceptedCount: 10},
+		DeliveryGuarantee:        AtLeastOnce,
+		Verdict:                  Verdict{Passed: false, Reason: "lost count\ndetails"},
+	}
+
+	tests := []struct {
+		name   string
+		format ReportFormat
+		check  func(t *testing.T, b []byte)
+	}{
+		{
+			name:   "json",
+			format: JSONReportFormat,
+			check: func(t *testing.T, b []byte) {
+				got := Report{}
+				if err := json.Unmarshal(b, &got); err != nil {
+					t.Fatal(err)
+				}
+				if got.LostCount != report.LostCount || got.Verdict != report.Verdict {
+					t.Errorf("want %+v, got %+v", report, got)
+				}
+			},
+		},
+		{
+			name:   "yaml",
+			format: YAMLReportFormat,
+			check: func(t *testing.T, b []byte) {
+				got := map[string]interface{}{}
+				if err := yaml.Unmarshal(b, &got); err != nil {
+					t.Fatal(err)
+				}
+				if got["lostCount"] != report.LostCount {
+					t.Errorf("want lostCount %d, got %v", report.LostCount, got["lostCount"])
+				}
+			},
+		},
+		{
+			name:   "markdown",
+			format: MarkdownReportFormat,
+			check: func(t *testing.T, b []byte) {
+				for _, want := range []string{"**Verdict**: FAILED", "| Lost | 1 |", "| unknown | 1 |"} {
+					if !strings.Contains(string(b), want) {
+						t.Errorf("want %q in:\n%s", want, string(b))
+					}
+				}
+			},
+		},
+		{
+			name:   "junit",
+			format: JUnitReportFormat,
+			check: func(t *testing.T, b []byte) {
+				got := junitTestSuites{}
+				if err := xml.Unmarshal(b, &got); err != nil {
+					t.Fatal(err)
+				}
+				if len(got.TestSuites) != 1 || got.TestSuites[0].Failures != 1 {
+					t.Fatalf("want 1 test suite with 1 failure, got %+v", got)
+				}
+				if f := got.TestSuites[0].TestCases[0].Failure; f == nil || f.Message != "lost count" {
+					t.Errorf("want failure with message %q, got %+v", "lost count", f)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &bytes.Buffer{}
+			if err := WriteReport(b, tt.format, report); err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, b.Bytes())
+		})
+	}
+}
+
+func TestParseReportFormat(t *testing.T) {
+	if f, err := ParseReportFormat(""); err != nil || f != JSONReportFormat {
+		t.Errorf("want %q, got %q (%v)", JSONReportFormat, f, err)
+	}
+	if _, err := ParseReportFormat("html"); err == nil {
+		t.Error("want error for unknown format")
+	}
+}
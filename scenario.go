@@ -0,0 +1,91 @@
+package sacura
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	ce "github.com/cloudevents/sdk-go/v2"
+)
+
+// runScenario runs the phases of the configured scenario one after the other, it stops when ctx is done.
+func (s *sender) runScenario(ctx context.Context, config Config, sentOut chan<- ce.Event) (Metrics, error) {
+	total := Metrics{}
+
+	for i, phase := range config.Scenario {
+		if ctx.Err() != nil {
+			break
+		}
+
+		s.logger.Printf("Starting phase %s ...\n", phase.Name)
+		m, err := s.attack(ctx, phase.apply(config), phase.Name, sentOut)
+		if err != nil {
+			return Metrics{}, fmt.Errorf("phase %s: %w", phase.Name, err)
+		}
+		s.logger.Printf("Phase %s finished, accepted %d of %d events\n", phase.Name, m.AcceptedCount, m.ProposedCount)
+
+		total.ProposedCount += m.ProposedCount
+		total.AcceptedCount += m.AcceptedCount
+		total.RejectedCount += m.RejectedCount
+		total.RejectedIDs = append(total.RejectedIDs, m.RejectedIDs...)
+		total.UncertainCount += m.UncertainCount
+		total.UncertainIDs = append(total.UncertainIDs, m.UncertainIDs...)
+		total.Phases = append(total.Phases, PhaseMetrics{Name: phase.Name, Metrics: m})
+
+		if phase.ParsedPause > 0 && i < len(config.Scenario)-1 {
+			s.logger.Printf("Pausing for %v ...\n", phase.ParsedPause)
+			select {
+			case <-time.After(phase.ParsedPause):
+			case <-ctx.Done():
+			}
+		}
+	}
+
+	s.total.Close()
+	total.Metrics = s.total
+	return total, nil
+}
+
+// apply returns the configuration of the sender during the phase.
+func (p PhaseConfig) apply(config Config) Config {
+	config.ParsedDuration = p.ParsedDuration
+	if p.Target != "" && len(config.Sender.Targets) > 0 {
+		// Keep sending to multiple targets, so that events sent in the phase are attributed to the phase target.
+		config.Sender.Targets = []TargetConfig{{URL: p.Target, Weight: 1}}
+	} else if p.Target != "" {
+		config.Sender.Target = p.Target
+	}
+	if p.Frequency > 0 {
+		config.Sender.FrequencyPerSecond = p.Frequency
+		config.Sender.Profile = nil
+	}
+	if p.Profile != nil {
+		config.Sender.Profile = p.Profile
+	}
+	if p.Event != nil {
+		config.Sender.Event = p.Event
+	}
+	return config
+}
+
+// eventPhase returns the scenario phase the given event has been sent in, it returns an empty string for events sent
+// without a scenario.
+func eventPhase(e *ce.Event) string {
+	if v, ok := e.Extensions()[PhaseExtension]; ok {
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+// faultConfig returns the fault configuration for a request carrying the given event, requests carrying events sent
+// in a scenario phase with a fault configuration get the fault configuration of the phase.
+//
+// e can be nil when the request doesn't carry events.
+func (c *ReceiverConfig) faultConfig(e *ce.Event) *ReceiverFaultConfig {
+	if e != nil {
+		if f, ok := c.ParsedPhaseFaults[eventPhase(e)]; ok {
+			return f
+		}
+	}
+	return c.ReceiverFaultConfig
+}
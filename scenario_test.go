@@ -0,0 +1,60 @@
+package sacura
+
+import (
+	"testing"
+
+	ce "github.com/cloudevents/sdk-go/v2"
+)
+
+func TestReceiverConfigFaultConfig(t *testing.T) {
+
+	defaultFault := &ReceiverFaultConfig{ErrorProbability: 0.1}
+	phaseFault := &ReceiverFaultConfig{DropProbability: 1}
+	config := &ReceiverConfig{
+		ReceiverFaultConfig: defaultFault,
+		ParsedPhaseFaults:   map[string]*ReceiverFaultConfig{"fault": phaseFault},
+	}
+
+	newEvent := func(phase string) *ce.Event {
+		e := ce.NewEvent()
+		if phase != "" {
+			e.SetExtension(PhaseExtension, phase)
+		}
+		return &e
+	}
+
+	tests := []struct {
+		name  string
+		event *ce.Event
+		want  *ReceiverFaultConfig
+	}{
+		{
+			name:  "no event",
+			event: nil,
+			want:  defaultFault,
+		},
+		{
+			name:  "no phase",
+			event: newEvent(""),
+			want:  defaultFault,
+		},
+		{
+			name:  "phase without fault",
+			event: newEvent("steady"),
+			want:  defaultFault,
+		},
+		{
+			name:  "phase with fault",
+			event: newEvent("fault"),
+			want:  phaseFault,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := config.faultConfig(tt.event); got != tt.want {
+				t.Errorf("want %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package sacura
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+	// ConfigSchemaID is the ID of the JSON Schema of the configuration file.
+	ConfigSchemaID = "https://github.com/pierdipi/sacura/schema/config.schema.json"
+)
+
+// jsonSchema is the subset of JSON Schema used to describe the configuration file.
+type jsonSchema struct {
+	Schema      string                 `json:"$schema,omitempty"`
+	ID          string                 `json:"$id,omitempty"`
+	Ref         string                 `json:"$ref,omitempty"`
+	Title       string                 `json:"title,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Type        string                 `json:"type,omitempty"`
+	Enum        []string               `json:"enum,omitempty"`
+	Minimum     *int                   `json:"minimum,omitempty"`
+	Properties  map[string]*jsonSchema `json:"properties,omitempty"`
+	// AdditionalProperties is false for structs and the schema of the values for maps.
+	AdditionalProperties interface{}            `json:"additionalProperties,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	Defs                 map[string]*jsonSchema `json:"$defs,omitempty"`
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+const durationDescription = "Duration, for example 1m30s"
+
+// schemaEnums are the allowed values of the string types of the configuration.
+var schemaEnums = map[reflect.Type][]string{
+	reflect.TypeOf(DeliveryGuarantee("")): {string(AtLeastOnce), string(AtMostOnce), string(ExactlyOnce)},
+	reflect.TypeOf(TargetSelection("")):   {string(WeightedTargetSelection), string(RoundRobinTargetSelection)},
+	reflect.TypeOf(SourceFormat("")):      {string(CloudEventsSourceFormat), string(VegetaSourceFormat)},
+	reflect.TypeOf(Encoding("")):          {string(StructuredEncoding), string(BinaryEncoding), string(BatchEncoding)},
+	reflect.TypeOf(Compression("")):       {string(NoCompression), string(GzipCompression)},
+	reflect.TypeOf(ReportFormat("")): {
+		string(JSONReportFormat), string(YAMLReportFormat), string(MarkdownReportFormat), string(JUnitReportFormat),
+	},
+}
+
+// ConfigJSONSchema returns the JSON Schema of the configuration file generated from Config.
+//
+// Only the structure of the configuration is described, constraints between fields are checked by FileConfig.
+func ConfigJSONSchema() ([]byte, error) {
+	defs := make(map[string]*jsonSchema)
+	root := schemaFor(reflect.TypeOf(Config{}), defs)
+	root.Schema = jsonSchemaDraft
+	root.ID = ConfigSchemaID
+	root.Title = "sacura configuration"
+	root.Defs = defs
+
+	b, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal JSON Schema: %w", err)
+	}
+	return append(b, '\n'), nil
+}
+
+// schemaFor returns the schema of the given type, named structs are added to defs and referenced, so that recursive
+// types are supported.
+func schemaFor(t reflect.Type, defs map[string]*jsonSchema) *jsonSchema {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == durationType {
+		return &jsonSchema{Type: "string", Description: durationDescription}
+	}
+	if values, ok := schemaEnums[t]; ok {
+		return &jsonSchema{Type: "string", Enum: values}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &jsonSchema{Type: "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		zero := 0
+		return &jsonSchema{Type: "integer", Minimum: &zero}
+	case reflect.Float32, reflect.Float64:
+		return &jsonSchema{Type: "number"}
+	case reflect.String:
+		return &jsonSchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &jsonSchema{Type: "array", Items: schemaFor(t.Elem(), defs)}
+	case reflect.Map:
+		return &jsonSchema{Type: "object", AdditionalProperties: schemaFor(t.Elem(), defs)}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return structSchema(t, defs)
+		}
+		if t == reflect.TypeOf(Config{}) {
+			// The root is inlined.
+			return structSchema(t, defs)
+		}
+		if _, ok := defs[t.Name()]; !ok {
+			// Register the name before visiting fields, so that recursive types reference it.
+			defs[t.Name()] = nil
+			defs[t.Name()] = structSchema(t, defs)
+		}
+		return &jsonSchema{Ref: "#/$defs/" + t.Name()}
+	}
+	// Any value.
+	return &jsonSchema{}
+}
+
+func structSchema(t reflect.Type, defs map[string]*jsonSchema) *jsonSchema {
+	s := &jsonSchema{
+		Type:                 "object",
+		Properties:           make(map[string]*jsonSchema, t.NumField()),
+		AdditionalProperties: false,
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+		if f.PkgPath != "" || name == "" || name == "-" {
+			// Unexported and parsed fields aren't part of the configuration file.
+			continue
+		}
+		s.Properties[name] = schemaFor(f.Type, defs)
+		if parsed, ok := t.FieldByName("Parsed" + f.Name); ok && f.Type.Kind() == reflect.String &&
+			(parsed.Type == durationType || parsed.Type == reflect.PtrTo(durationType)) {
+			// Durations parsed during validation are strings in the configuration file.
+			s.Properties[name].Description = durationDescription
+		}
+	}
+	return s
+}
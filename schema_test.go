@@ -0,0 +1,41 @@
+package sacura
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestConfigJSONSchema(t *testing.T) {
+	got, err := ConfigJSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := os.ReadFile("schema/config.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(string(want), string(got)); diff != "" {
+		t.Errorf("schema/config.schema.json is out of date, run ./hack/update-codegen.sh (-want, +got) %s", diff)
+	}
+
+	var schema jsonSchema
+	if err := json.Unmarshal(got, &schema); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := schema.Properties["sender"]; !ok {
+		t.Errorf("want sender property, got %v", schema.Properties)
+	}
+	for _, parsed := range []string{"ParsedDuration", "parsedduration"} {
+		if _, ok := schema.Properties[parsed]; ok {
+			t.Errorf("want no %s property", parsed)
+		}
+	}
+	paths := schema.Defs["ReceiverFaultConfig"].Properties["paths"]
+	if ref := paths.AdditionalProperties.(map[string]interface{})["$ref"]; ref != "#/$defs/ReceiverFaultConfig" {
+		t.Errorf("want recursive reference to ReceiverFaultConfig, got %v", ref)
+	}
+}
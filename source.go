@@ -0,0 +1,214 @@
+package sacura
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	ce "github.com/cloudevents/sdk-go/v2"
+	ceformat "github.com/cloudevents/sdk-go/v2/binding/format"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+	"go.uber.org/atomic"
+)
+
+const (
+	// maxLineSize is the maximum size of a line of a source file.
+	maxLineSize = 16 * 1024 * 1024
+)
+
+// fileSource replays events read from a file.
+type fileSource struct {
+	config *FileSourceConfig
+	events []ce.Event
+	// offsets are the times of the events relative to the first event.
+	offsets []time.Duration
+	next    *atomic.Uint64
+}
+
+// newFileSource creates a source replaying the events of the given configuration, the file is read when its events
+// haven't been parsed yet.
+func newFileSource(config *FileSourceConfig) (*fileSource, error) {
+	events := config.ParsedEvents
+	if events == nil {
+		var err error
+		if events, err = readSourceFile(config); err != nil {
+			return nil, err
+		}
+	}
+
+	return &fileSource{
+		config:  config,
+		events:  events,
+		offsets: eventOffsets(events),
+		next:    atomic.NewUint64(0),
+	}, nil
+}
+
+// readSourceFile reads the events of the file configured in the given configuration.
+func readSourceFile(config *FileSourceConfig) ([]ce.Event, error) {
+	f, err := os.Open(config.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source file %s: %w", config.Path, err)
+	}
+	defer f.Close()
+
+	var events []ce.Event
+	switch config.Format {
+	case VegetaSourceFormat:
+		events, err = readVegetaTargets(f)
+	default:
+		events, err = readCloudEvents(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source file %s: %w", config.Path, err)
+	}
+	if len(events) == 0 {
+		return nil, fmt.Errorf("source file %s has no events", config.Path)
+	}
+	return events, nil
+}
+
+func readCloudEvents(f *os.File) ([]ce.Event, error) {
+	var events []ce.Event
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for line := 1; scanner.Scan(); line++ {
+		b := bytes.TrimSpace(scanner.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+		e := ce.NewEvent()
+		if err := ceformat.JSON.Unmarshal(b, &e); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		events = append(events, e)
+	}
+	return events, scanner.Err()
+}
+
+func readVegetaTargets(f *os.File) ([]ce.Event, error) {
+	var events []ce.Event
+
+	targeter := vegeta.NewJSONTargeter(f, nil, nil)
+	for i := 1; ; i++ {
+		t := vegeta.Target{}
+		if err := targeter(&t); errors.Is(err, vegeta.ErrNoTargets) {
+			return events, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("target %d: %w", i, err)
+		}
+		req, err := t.Request()
+		if err != nil {
+			return nil, fmt.Errorf("target %d: %w", i, err)
+		}
+		e, err := readEvents(context.Background(), req)
+		if err != nil {
+			return nil, fmt.Errorf("target %d: %w", i, err)
+		}
+		events = append(events, e...)
+	}
+}
+
+// eventOffsets returns the time of each event relative to the first event.
+//
+// Events without time or with a time before the time of the previous event are sent right after the previous event.
+func eventOffsets(events []ce.Event) []time.Duration {
+	offsets := make([]time.Duration, len(events))
+	first := events[0].Time()
+	for i, e := range events {
+		if i == 0 {
+			continue
+		}
+		offsets[i] = offsets[i-1]
+		if !first.IsZero() && !e.Time().IsZero() && e.Time().Sub(first) > offsets[i] {
+			offsets[i] = e.Time().Sub(first)
+		}
+	}
+	return offsets
+}
+
+// eventGenerator returns an EventGenerator replaying the source events.
+//
+// The generator returns vegeta.ErrNoTargets when all events have been replayed and the source doesn't loop, which
+// stops the attack.
+func (s *fileSource) eventGenerator() EventGenerator {
+	return func(id string) (ce.Event, error) {
+		i := s.next.Inc() - 1
+		if !s.config.Loop && i >= uint64(len(s.events)) {
+			return ce.Event{}, vegeta.ErrNoTargets
+		}
+
+		e := s.events[i%uint64(len(s.events))].Clone()
+		if s.config.RewriteIDs {
+			e.SetID(id)
+		}
+		return e, nil
+	}
+}
+
+// pacer returns a pacer replaying the source events at their original pace, requests carrying batchSize events are
+// sent at the time of their first event.
+func (s *fileSource) pacer(batchSize int) vegeta.Pacer {
+	period := time.Second
+	if n := len(s.offsets); n > 1 {
+		last := s.offsets[n-1]
+		// Leave the average gap between the last event and the first event of the next loop.
+		period = last + last/time.Duration(n-1)
+	}
+	if period <= 0 {
+		period = time.Second
+	}
+	return replayPacer{Offsets: s.offsets, Loop: s.config.Loop, Period: period, BatchSize: batchSize}
+}
+
+// replayPacer sends events at the given offsets, when Loop is true offsets are repeated every Period.
+//
+// Every hit sends BatchSize events, a BatchSize less than 1 is the same as 1.
+type replayPacer struct {
+	Offsets   []time.Duration
+	Loop      bool
+	Period    time.Duration
+	BatchSize int
+}
+
+var _ vegeta.Pacer = replayPacer{}
+
+func (p replayPacer) Pace(elapsed time.Duration, hits uint64) (time.Duration, bool) {
+	if !p.Loop && hits*uint64(p.batchSize()) >= uint64(len(p.Offsets)) {
+		return 0, true
+	}
+	return paceHits(p, elapsed, hits)
+}
+
+func (p replayPacer) Rate(time.Duration) float64 {
+	return float64(len(p.Offsets)) / float64(p.batchSize()) / p.Period.Seconds()
+}
+
+func (p replayPacer) batchSize() int {
+	if p.BatchSize < 1 {
+		return 1
+	}
+	return p.BatchSize
+}
+
+func (p replayPacer) hits(t time.Duration) float64 {
+	if t < 0 {
+		return 0
+	}
+	loops := 0
+	if p.Loop {
+		loops = int(t / p.Period)
+		t = t % p.Period
+	}
+	// Number of offsets less than or equal to t.
+	n := sort.Search(len(p.Offsets), func(i int) bool { return p.Offsets[i] > t })
+	// A hit is due when its first event is due.
+	return math.Ceil(float64(loops*len(p.Offsets)+n) / float64(p.batchSize()))
+}
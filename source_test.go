@@ -0,0 +1,249 @@
+package sacura
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	ce "github.com/cloudevents/sdk-go/v2"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/uuid"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+const sourceEvents = `{"specversion":"1.0","id":"1","source":"/sacura","type":"dev.sacura.test","time":"2022-01-01T00:00:00Z","data":{"n":1}}
+{"specversion":"1.0","id":"2","source":"/sacura","type":"dev.sacura.test","time":"2022-01-01T00:00:01Z","data":{"n":2}}
+
+{"specversion":"1.0","id":"3","source":"/sacura","type":"dev.sacura.test","time":"2022-01-01T00:00:03Z","data":{"n":3}}
+`
+
+const sourceTargets = `{"method":"POST","url":"http://localhost:8080","header":{"Content-Type":["application/cloudevents+json"]},"body":"eyJzcGVjdmVyc2lvbiI6IjEuMCIsImlkIjoiMSIsInNvdXJjZSI6Ii9zYWN1cmEiLCJ0eXBlIjoiZGV2LnNhY3VyYS50ZXN0In0="}
+{"method":"POST","url":"http://localhost:8080","header":{"Ce-Specversion":["1.0"],"Ce-Id":["2"],"Ce-Source":["/sacura"],"Ce-Type":["dev.sacura.test"]}}
+`
+
+func TestNewFileSource(t *testing.T) {
+
+	tests := []struct {
+		name        string
+		content     string
+		config      FileSourceConfig
+		generate    int
+		wantIDs     []string
+		wantOffsets []time.Duration
+		wantErr     bool
+	}{
+		{
+			name:        "cloudevents",
+			content:     sourceEvents,
+			config:      FileSourceConfig{Format: CloudEventsSourceFormat},
+			generate:    4,
+			wantIDs:     []string{"1", "2", "3"},
+			wantOffsets: []time.Duration{0, time.Second, 3 * time.Second},
+		},
+		{
+			name:        "loop with rewritten IDs",
+			content:     sourceEvents,
+			config:      FileSourceConfig{Format: CloudEventsSourceFormat, Loop: true, RewriteIDs: true},
+			generate:    5,
+			wantIDs:     []string{"id-0", "id-1", "id-2", "id-3", "id-4"},
+			wantOffsets: []time.Duration{0, time.Second, 3 * time.Second},
+		},
+		{
+			name:        "vegeta",
+			content:     sourceTargets,
+			config:      FileSourceConfig{Format: VegetaSourceFormat},
+			generate:    3,
+			wantIDs:     []string{"1", "2"},
+			wantOffsets: []time.Duration{0, 0},
+		},
+		{
+			name:    "invalid event",
+			content: "{}\n",
+			config:  FileSourceConfig{Format: CloudEventsSourceFormat},
+			wantErr: true,
+		},
+		{
+			name:    "empty",
+			content: "\n",
+			config:  FileSourceConfig{Format: CloudEventsSourceFormat},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.Path = filepath.Join(t.TempDir(), "events.jsonl")
+			if err := os.WriteFile(tt.config.Path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			s, err := newFileSource(&tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newFileSource() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if len(s.offsets) != len(tt.wantOffsets) {
+				t.Fatalf("want offsets %v, got %v", tt.wantOffsets, s.offsets)
+			}
+			for i := range s.offsets {
+				if s.offsets[i] != tt.wantOffsets[i] {
+					t.Errorf("want offsets %v, got %v", tt.wantOffsets, s.offsets)
+				}
+			}
+
+			newEvent := s.eventGenerator()
+			var ids []string
+			for i := 0; i < tt.generate; i++ {
+				e, err := newEvent(fmt.Sprintf("id-%d", i))
+				if errors.Is(err, vegeta.ErrNoTargets) {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				ids = append(ids, e.ID())
+			}
+			if strings.Join(ids, ",") != strings.Join(tt.wantIDs, ",") {
+				t.Errorf("want IDs %v, got %v", tt.wantIDs, ids)
+			}
+		})
+	}
+}
+
+func TestReplayPacer(t *testing.T) {
+
+	offsets := []time.Duration{0, time.Second, 3 * time.Second}
+
+	tests := []struct {
+		name     string
+		pacer    replayPacer
+		elapsed  time.Duration
+		hits     uint64
+		wantWait time.Duration
+		wantStop bool
+	}{
+		{
+			name:     "first",
+			pacer:    replayPacer{Offsets: offsets, Period: 4 * time.Second},
+			elapsed:  0,
+			hits:     0,
+			wantWait: 0,
+		},
+		{
+			name:     "second",
+			pacer:    replayPacer{Offsets: offsets, Period: 4 * time.Second},
+			elapsed:  500 * time.Millisecond,
+			hits:     1,
+			wantWait: 500 * time.Millisecond,
+		},
+		{
+			name:     "exhausted",
+			pacer:    replayPacer{Offsets: offsets, Period: 4 * time.Second},
+			elapsed:  3 * time.Second,
+			hits:     3,
+			wantStop: true,
+		},
+		{
+			name:     "loop",
+			pacer:    replayPacer{Offsets: offsets, Loop: true, Period: 4 * time.Second},
+			elapsed:  3 * time.Second,
+			hits:     3,
+			wantWait: time.Second,
+		},
+		{
+			name:     "batch at the first event of the batch",
+			pacer:    replayPacer{Offsets: offsets, Period: 4 * time.Second, BatchSize: 2},
+			elapsed:  500 * time.Millisecond,
+			hits:     1,
+			wantWait: 2500 * time.Millisecond,
+		},
+		{
+			name:     "batch exhausted with a partial batch",
+			pacer:    replayPacer{Offsets: offsets, Period: 4 * time.Second, BatchSize: 2},
+			elapsed:  3 * time.Second,
+			hits:     2,
+			wantStop: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wait, stop := tt.pacer.Pace(tt.elapsed, tt.hits)
+			if stop != tt.wantStop {
+				t.Fatalf("want stop %v, got %v", tt.wantStop, stop)
+			}
+			if stop {
+				return
+			}
+			if d := wait - tt.wantWait; d < -time.Millisecond || d > time.Millisecond {
+				t.Errorf("want wait %v, got %v", tt.wantWait, wait)
+			}
+		})
+	}
+}
+
+func TestFileSourceParsedEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	if err := os.WriteFile(path, []byte(sourceEvents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := &SourceConfig{File: &FileSourceConfig{Path: path}}
+	if err := config.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The file isn't read again once its events have been parsed.
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	s, err := newFileSource(config.File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.events) != 3 {
+		t.Errorf("want 3 events, got %d", len(s.events))
+	}
+}
+
+func TestFileSourcePartialBatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	if err := os.WriteFile(path, []byte(sourceEvents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := Config{Sender: SenderConfig{
+		Target:    "http://localhost:8080",
+		Encoding:  BatchEncoding,
+		BatchSize: 2,
+		Source:    &SourceConfig{File: &FileSourceConfig{Path: path, Format: CloudEventsSourceFormat}},
+	}}
+
+	out := make(chan ce.Event, 3)
+	targeter := NewTargeterGenerator(config, uuid.New, out)
+
+	var ids [][]string
+	for {
+		target := &vegeta.Target{}
+		err := targeter(target)
+		if errors.Is(err, vegeta.ErrNoTargets) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, target.Header.Values(CloudEventIdHeader))
+	}
+
+	if diff := cmp.Diff([][]string{{"1", "2"}, {"3"}}, ids); diff != "" {
+		t.Errorf("(-want, +got) %s", diff)
+	}
+	if !isSourceExhausted(&vegeta.Result{Error: vegeta.ErrNoTargets.Error()}) {
+		t.Error("want the source exhausted result to be excluded from the metrics")
+	}
+}
@@ -2,8 +2,10 @@ package sacura
 
 import (
 	"encoding/json"
+	"fmt"
 	"sort"
 	"sync"
+	"time"
 
 	ce "github.com/cloudevents/sdk-go/v2"
 	"k8s.io/apimachinery/pkg/util/sets"
@@ -18,6 +20,39 @@ type StateManager struct {
 	received map[string][]string
 	sent     map[string][]string
 
+	// receivedIDs are the unique received event IDs.
+	receivedIDs    sets.String
+	acceptedCount  int
+	duplicateCount int
+	// outstanding are the accepted but not yet received event IDs with the time they have been accepted.
+	outstanding map[string]time.Time
+	// outstandingQueue are accepted event IDs in acceptance order starting from outstandingHead, it might contain IDs
+	// that have been received after the head.
+	outstandingQueue []string
+	outstandingHead  int
+
+	// sentByPhase are the accepted event IDs by scenario phase.
+	sentByPhase map[string][]string
+	// sentByTarget are the accepted event IDs by target, they're only collected with multiple targets.
+	sentByTarget map[string][]string
+
+	// otherRuns are the number of events received from other runs by run ID.
+	otherRuns map[string]int
+
+	// authRejections are the number of events in requests rejected by the receiver authentication by reason.
+	authRejections map[AuthRejection]int
+
+	// repliesSent are the IDs of the reply events sent by the receiver.
+	repliesSent sets.String
+	// repliesReceived are the IDs of the received reply events, including duplicates.
+	repliesReceived []string
+	// misroutedReplies are the IDs of the reply events received on a path other than the reply path.
+	misroutedReplies []string
+
+	// integrityViolations are the IDs of the received events failing the integrity verification by kind.
+	integrityViolations     map[IntegrityViolation][]string
+	integrityViolationCount int
+
 	config             Config
 	stateManagerConfig StateManagerConfig
 
@@ -40,13 +75,36 @@ func stateManagerConfigFromConfig(config Config) StateManagerConfig {
 	return StateManagerConfig{Ordered: false}
 }
 
+// Progress is a snapshot of the state of a run.
+type Progress struct {
+	// AcceptedCount is the number of events accepted so far.
+	AcceptedCount int `json:"acceptedCount"`
+	// ReceivedCount is the number of unique events received so far.
+	ReceivedCount int `json:"receivedCount"`
+	// OutstandingCount is the number of events accepted but not yet received.
+	OutstandingCount int `json:"outstandingCount"`
+	// DuplicateCount is the number of duplicate events received so far.
+	DuplicateCount int `json:"duplicateCount"`
+	// OldestOutstandingAge is the time since the oldest outstanding event has been accepted.
+	OldestOutstandingAge time.Duration `json:"oldestOutstandingAge"`
+}
+
 func NewStateManager(config Config) *StateManager {
-	return &StateManager{
-		received:           make(map[string][]string),
-		sent:               make(map[string][]string),
-		config:             config,
-		stateManagerConfig: stateManagerConfigFromConfig(config),
+	s := &StateManager{
+		received:            make(map[string][]string),
+		sent:                make(map[string][]string),
+		sentByPhase:         make(map[string][]string),
+		sentByTarget:        make(map[string][]string),
+		receivedIDs:         sets.NewString(),
+		outstanding:         make(map[string]time.Time),
+		integrityViolations: make(map[IntegrityViolation][]string),
+		otherRuns:           make(map[string]int),
+		authRejections:      make(map[AuthRejection]int),
+		repliesSent:         sets.NewString(),
+		config:              config,
+		stateManagerConfig:  stateManagerConfigFromConfig(config),
 	}
+	return s
 }
 
 func (s *StateManager) ReadSent(sent <-chan ce.Event) <-chan struct{} {
@@ -54,9 +112,21 @@ func (s *StateManager) ReadSent(sent <-chan ce.Event) <-chan struct{} {
 	go func(set *StateManager) {
 		for e := range sent {
 			func() {
-				s.lock.RLock()
-				defer s.lock.RUnlock()
+				s.lock.Lock()
+				defer s.lock.Unlock()
 				insert(&e, s.sent, &s.stateManagerConfig)
+				if phase := eventPhase(&e); phase != "" {
+					s.sentByPhase[phase] = append(s.sentByPhase[phase], e.ID())
+				}
+				if target := eventTarget(&e); target != "" {
+					s.sentByTarget[target] = append(s.sentByTarget[target], e.ID())
+				}
+
+				s.acceptedCount++
+				if !s.receivedIDs.Has(e.ID()) {
+					s.outstanding[e.ID()] = time.Now()
+					s.outstandingQueue = append(s.outstandingQueue, e.ID())
+				}
 			}()
 		}
 		sg <- struct{}{}
@@ -69,9 +139,33 @@ func (s *StateManager) ReadReceived(received <-chan ce.Event) <-chan struct{} {
 	go func(set *StateManager) {
 		for e := range received {
 			func() {
-				s.lock.RLock()
-				defer s.lock.RUnlock()
+				s.lock.Lock()
+				defer s.lock.Unlock()
+
+				if runID := otherRunID(&e, s.config.RunID); runID != "" {
+					s.otherRuns[runID]++
+					return
+				}
+
 				insert(&e, s.received, &s.stateManagerConfig)
+
+				if s.receivedIDs.Has(e.ID()) {
+					s.duplicateCount++
+				} else {
+					s.receivedIDs.Insert(e.ID())
+					delete(s.outstanding, e.ID())
+					s.trimOutstandingQueue()
+				}
+
+				if s.config.Integrity != nil {
+					violations := verifyChecksums(&e)
+					for _, v := range violations {
+						s.integrityViolations[v] = append(s.integrityViolations[v], e.ID())
+					}
+					if len(violations) > 0 {
+						s.integrityViolationCount++
+					}
+				}
 			}()
 		}
 		sg <- struct{}{}
@@ -79,6 +173,82 @@ func (s *StateManager) ReadReceived(received <-chan ce.Event) <-chan struct{} {
 	return sg
 }
 
+// authRejected counts the events in a request rejected by the receiver authentication, events from other runs aren't
+// counted.
+func (s *StateManager) authRejected(reason AuthRejection, events []ce.Event) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for i := range events {
+		if otherRunID(&events[i], s.config.RunID) == "" {
+			s.authRejections[reason]++
+		}
+	}
+}
+
+// replySent tracks a reply event sent by the receiver, so that it's expected to be received.
+func (s *StateManager) replySent(reply ce.Event) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if otherRunID(&reply, s.config.RunID) == "" {
+		s.repliesSent.Insert(reply.ID())
+	}
+}
+
+// replyReceived tracks a reply event received on the given path.
+func (s *StateManager) replyReceived(reply ce.Event, path string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if runID := otherRunID(&reply, s.config.RunID); runID != "" {
+		s.otherRuns[runID]++
+		return
+	}
+	s.repliesReceived = append(s.repliesReceived, reply.ID())
+	if r := s.config.Receiver.Reply; r == nil || r.Path != path {
+		s.misroutedReplies = append(s.misroutedReplies, reply.ID())
+	}
+}
+
+// replyReport reports the reply events, replies are a second generation of events: they're sent by the receiver
+// and they must come back to the receiver.
+func (s *StateManager) replyReport() *ReplyReport {
+	received, duplicates := removeDuplicates(s.repliesReceived)
+	r := &ReplyReport{
+		SentCount:      s.repliesSent.Len(),
+		ReceivedCount:  len(received),
+		DuplicateCount: len(duplicates),
+		MisroutedCount: len(s.misroutedReplies),
+		Duplicates:     sets.NewString(duplicates...).List(),
+		Misrouted:      sets.NewString(s.misroutedReplies...).List(),
+	}
+	receivedIDs := sets.NewString(received...)
+	r.Lost = s.repliesSent.Difference(receivedIDs).List()
+	r.LostCount = len(r.Lost)
+	r.Unknown = receivedIDs.Difference(s.repliesSent).List()
+	r.UnknownCount = len(r.Unknown)
+	return r
+}
+
+// otherRunID returns the run ID of the given event when it has been sent by a run other than runID, otherwise it
+// returns an empty string.
+//
+// Events without a run ID aren't considered from other runs.
+func otherRunID(e *ce.Event, runID string) string {
+	if runID == "" {
+		return ""
+	}
+	v, ok := e.Extensions()[RunIDExtension]
+	if !ok {
+		return ""
+	}
+	if id := fmt.Sprint(v); id != runID {
+		return id
+	}
+	return ""
+}
+
 func insert(e *ce.Event, store map[string][]string, config *StateManagerConfig) {
 	pk := unknownPartitionKey
 	if config.Ordered {
@@ -104,6 +274,41 @@ func (s *StateManager) ReceivedCount() int {
 	return count
 }
 
+// Progress returns a snapshot of the state of the run, it can be called while events are sent and received.
+func (s *StateManager) Progress() Progress {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	p := Progress{
+		AcceptedCount:    s.acceptedCount,
+		ReceivedCount:    s.receivedIDs.Len(),
+		OutstandingCount: len(s.outstanding),
+		DuplicateCount:   s.duplicateCount,
+	}
+	if s.outstandingHead < len(s.outstandingQueue) {
+		p.OldestOutstandingAge = time.Since(s.outstanding[s.outstandingQueue[s.outstandingHead]])
+	}
+	return p
+}
+
+// trimOutstandingQueue drops received events from the head of the queue, so that the head is the oldest outstanding
+// event, it must be called with the lock held.
+//
+// The queue is compacted once at least half of it has been dropped, so that received events don't keep the backing
+// array alive.
+func (s *StateManager) trimOutstandingQueue() {
+	for s.outstandingHead < len(s.outstandingQueue) {
+		if _, ok := s.outstanding[s.outstandingQueue[s.outstandingHead]]; ok {
+			break
+		}
+		s.outstandingHead++
+	}
+	if s.outstandingHead > 0 && s.outstandingHead >= len(s.outstandingQueue)/2 {
+		s.outstandingQueue = append([]string(nil), s.outstandingQueue[s.outstandingHead:]...)
+		s.outstandingHead = 0
+	}
+}
+
 func (s *StateManager) Diff() string {
 	report := s.GenerateReport()
 	if len(report.LostEventsByPartitionKey) > 0 {
@@ -124,18 +329,67 @@ func (s *StateManager) GenerateReport() Report {
 		DuplicateEventsByPartitionKey: make(map[string][]string, 8),
 		ReceivedEventsByPartitionKey:  make(map[string][]string, 8),
 		Terminated:                    s.terminated,
+		DeliveryGuarantee:             s.config.DeliveryGuarantee,
+		RunID:                         s.config.RunID,
+	}
+
+	if len(s.otherRuns) > 0 {
+		r.OtherRunsEventCounts = make(map[string]int, len(s.otherRuns))
+		for k, v := range s.otherRuns {
+			r.OtherRunsEventCounts[k] = v
+			r.OtherRunsCount += v
+		}
+	}
+
+	if len(s.authRejections) > 0 {
+		r.AuthRejections = make(map[AuthRejection]int, len(s.authRejections))
+		for k, v := range s.authRejections {
+			r.AuthRejections[k] = v
+			r.AuthRejectedCount += v
+		}
+	}
+
+	if s.config.Receiver.Reply != nil {
+		r.Replies = s.replyReport()
+	}
+
+	if s.stateManagerConfig.Ordered {
+		r.OrderingViolationsByPartitionKey = make(map[string]PartitionOrderingViolations, 8)
+	}
+
+	if s.config.Integrity != nil {
+		r.IntegrityViolationCount = s.integrityViolationCount
+		r.IntegrityViolations = make(map[IntegrityViolation][]string, len(s.integrityViolations))
+		for k, v := range s.integrityViolations {
+			ids := make([]string, len(v))
+			copy(ids, v)
+			sort.Strings(ids)
+			r.IntegrityViolations[k] = ids
+		}
+	}
+
+	if !s.config.Sender.Disabled {
+		s.unexpectedEvents(&r)
 	}
 
 	for k, v := range s.sent {
-		var sent []string
+		sent := make([]string, len(v))
 		copy(sent, v)
 		var received []string
 		var duplicates []string
 		if v, ok := s.received[k]; ok {
-			received, duplicates = removeDuplicates(v) // at least once TODO configurable delivery guarantee
+			received, duplicates = removeDuplicates(v)
 		}
 
-		if !s.stateManagerConfig.Ordered {
+		if s.stateManagerConfig.Ordered {
+			if violations := orderingViolations(sent, received); len(violations) > 0 {
+				r.OrderingViolationsByPartitionKey[k] = PartitionOrderingViolations{
+					Count:      len(violations),
+					Violations: violations,
+				}
+				r.OrderingViolationCount += len(violations)
+			}
+		} else {
 			sort.Strings(sent)
 			sort.Strings(received)
 			sort.Strings(duplicates)
@@ -156,9 +410,127 @@ func (s *StateManager) GenerateReport() Report {
 		}
 	}
 
+	if len(s.metrics.Phases) > 0 {
+		r.Phases = s.phaseReports(&r)
+	}
+	if len(s.metrics.Targets) > 0 {
+		r.Targets = s.targetReports(&r)
+	}
+
 	return r
 }
 
+// phaseReports splits the received, lost and duplicate events of the report by scenario phase.
+func (s *StateManager) phaseReports(r *Report) []PhaseReport {
+	counts := countByGroup(r, s.sentByPhase)
+	reports := make([]PhaseReport, 0, len(s.metrics.Phases))
+	for _, m := range s.metrics.Phases {
+		c := counts[m.Name]
+		reports = append(reports, PhaseReport{
+			Name:           m.Name,
+			ReceivedCount:  c.received,
+			LostCount:      c.lost,
+			DuplicateCount: c.duplicates,
+			Metrics:        m.Metrics,
+		})
+	}
+	return reports
+}
+
+// targetReports splits the received, lost and duplicate events of the report by target.
+func (s *StateManager) targetReports(r *Report) []TargetReport {
+	counts := countByGroup(r, s.sentByTarget)
+	reports := make([]TargetReport, 0, len(s.metrics.Targets))
+	for _, m := range s.metrics.Targets {
+		c := counts[m.Target]
+		reports = append(reports, TargetReport{
+			Target:         m.Target,
+			ReceivedCount:  c.received,
+			LostCount:      c.lost,
+			DuplicateCount: c.duplicates,
+			Metrics:        m.Metrics,
+		})
+	}
+	return reports
+}
+
+// groupCounts are the counts of the events accepted in a group of events, like a scenario phase or a target.
+type groupCounts struct {
+	received   int
+	lost       int
+	duplicates int
+}
+
+// countByGroup counts the received, lost and duplicate events of the report by the group the events have been
+// accepted in.
+func countByGroup(r *Report, sentByGroup map[string][]string) map[string]groupCounts {
+	received := sets.NewString()
+	for _, v := range r.ReceivedEventsByPartitionKey {
+		received.Insert(v...)
+	}
+
+	groups := make(map[string]string)
+	counts := make(map[string]groupCounts, len(sentByGroup))
+	for group, ids := range sentByGroup {
+		c := groupCounts{}
+		for _, id := range ids {
+			groups[id] = group
+			if received.Has(id) {
+				c.received++
+			} else {
+				c.lost++
+			}
+		}
+		counts[group] = c
+	}
+	for _, v := range r.DuplicateEventsByPartitionKey {
+		for _, id := range v {
+			if group, ok := groups[id]; ok {
+				c := counts[group]
+				c.duplicates++
+				counts[group] = c
+			}
+		}
+	}
+	return counts
+}
+
+// unexpectedEvents adds to the report the events received that were never accepted, distinguishing events rejected
+// by the system under test and events sent in requests that failed without a response from events that were never
+// sent.
+func (s *StateManager) unexpectedEvents(r *Report) {
+	accepted := sets.NewString()
+	for _, v := range s.sent {
+		accepted.Insert(v...)
+	}
+	rejected := sets.NewString(s.metrics.RejectedIDs...)
+	uncertain := sets.NewString(s.metrics.UncertainIDs...)
+
+	for k, v := range s.received {
+		for _, id := range sets.NewString(v...).Difference(accepted).List() {
+			if rejected.Has(id) {
+				if r.RejectedReceivedEventsByPartitionKey == nil {
+					r.RejectedReceivedEventsByPartitionKey = make(map[string][]string, 8)
+				}
+				r.RejectedReceivedEventsByPartitionKey[k] = append(r.RejectedReceivedEventsByPartitionKey[k], id)
+				r.RejectedReceivedCount++
+			} else if uncertain.Has(id) {
+				if r.UncertainReceivedEventsByPartitionKey == nil {
+					r.UncertainReceivedEventsByPartitionKey = make(map[string][]string, 8)
+				}
+				r.UncertainReceivedEventsByPartitionKey[k] = append(r.UncertainReceivedEventsByPartitionKey[k], id)
+				r.UncertainReceivedCount++
+			} else {
+				if r.UnknownEventsByPartitionKey == nil {
+					r.UnknownEventsByPartitionKey = make(map[string][]string, 8)
+				}
+				r.UnknownEventsByPartitionKey[k] = append(r.UnknownEventsByPartitionKey[k], id)
+				r.UnknownCount++
+			}
+		}
+	}
+}
+
 func (s *StateManager) Terminated(metrics Metrics) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
@@ -181,3 +553,66 @@ func removeDuplicates(a []string) ([]string, []string) {
 	}
 	return result, duplicates
 }
+
+// orderingViolations compares the order of received events with the order of sent events.
+//
+// Events received out of order are the ones that aren't part of the longest sequence of received events in sent
+// order, so that a single late event is a single violation rather than shifting every later position.
+//
+// Only events that are both sent and received are compared, so that lost and unexpected events
+// don't generate ordering violations. received is expected to be without duplicates.
+func orderingViolations(sent []string, received []string) []OrderingViolation {
+	receivedSet := sets.NewString(received...)
+
+	expected := make([]string, 0, len(sent))
+	sentIndex := make(map[string]int, len(sent))
+	for _, id := range sent {
+		if receivedSet.Has(id) {
+			sentIndex[id] = len(expected)
+			expected = append(expected, id)
+		}
+	}
+
+	actual := make([]string, 0, len(expected))
+	for _, id := range received {
+		if _, ok := sentIndex[id]; ok {
+			actual = append(actual, id)
+		}
+	}
+
+	// tails[l] is the position in actual of the smallest sent index ending an increasing sequence of length l+1,
+	// prev links every position to the previous one of its sequence.
+	tails := make([]int, 0, len(actual))
+	prev := make([]int, len(actual))
+	for position, id := range actual {
+		l := sort.Search(len(tails), func(i int) bool { return sentIndex[actual[tails[i]]] >= sentIndex[id] })
+		prev[position] = -1
+		if l > 0 {
+			prev[position] = tails[l-1]
+		}
+		if l == len(tails) {
+			tails = append(tails, position)
+		} else {
+			tails[l] = position
+		}
+	}
+
+	inOrder := make([]bool, len(actual))
+	if len(tails) > 0 {
+		for position := tails[len(tails)-1]; position >= 0; position = prev[position] {
+			inOrder[position] = true
+		}
+	}
+
+	var violations []OrderingViolation
+	for position, id := range actual {
+		if !inOrder[position] {
+			violations = append(violations, OrderingViolation{
+				Position:   position,
+				ExpectedID: expected[position],
+				ActualID:   id,
+			})
+		}
+	}
+	return violations
+}
@@ -8,6 +8,8 @@ import (
 
 	ce "github.com/cloudevents/sdk-go/v2"
 	cetest "github.com/cloudevents/sdk-go/v2/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"k8s.io/apimachinery/pkg/util/wait"
 )
 
@@ -90,3 +92,320 @@ func TestStateManager(t *testing.T) {
 		})
 	}
 }
+
+func TestStateManagerOrderingViolations(t *testing.T) {
+
+	tt := []struct {
+		name           string
+		sent           []string
+		received       []string
+		wantViolations []OrderingViolation
+	}{
+		{
+			name:     "in order",
+			sent:     []string{"1", "2", "3", "4"},
+			received: []string{"1", "2", "3", "4"},
+		},
+		{
+			name:     "in order with duplicates",
+			sent:     []string{"1", "2", "3", "4"},
+			received: []string{"1", "2", "2", "3", "4"},
+		},
+		{
+			name:     "in order with lost",
+			sent:     []string{"1", "2", "3", "4"},
+			received: []string{"1", "3", "4"},
+		},
+		{
+			name:     "swapped",
+			sent:     []string{"1", "2", "3", "4"},
+			received: []string{"1", "3", "2", "4"},
+			wantViolations: []OrderingViolation{
+				{Position: 1, ExpectedID: "2", ActualID: "3"},
+			},
+		},
+		{
+			name:     "single late event",
+			sent:     []string{"1", "2", "3", "4", "5"},
+			received: []string{"2", "3", "4", "5", "1"},
+			wantViolations: []OrderingViolation{
+				{Position: 4, ExpectedID: "5", ActualID: "1"},
+			},
+		},
+		{
+			name:     "single early event",
+			sent:     []string{"1", "2", "3", "4", "5"},
+			received: []string{"5", "1", "2", "3", "4"},
+			wantViolations: []OrderingViolation{
+				{Position: 0, ExpectedID: "1", ActualID: "5"},
+			},
+		},
+		{
+			name:     "two swapped pairs with lost and duplicates",
+			sent:     []string{"1", "2", "3", "4", "5"},
+			received: []string{"2", "1", "1", "4", "3"},
+			wantViolations: []OrderingViolation{
+				{Position: 0, ExpectedID: "1", ActualID: "2"},
+				{Position: 2, ExpectedID: "3", ActualID: "4"},
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			sent := make(chan ce.Event, len(tc.sent))
+			received := make(chan ce.Event, len(tc.received))
+
+			sm := NewStateManager(Config{Ordered: &OrderedConfig{NumPartitionKeys: 1}})
+			receivedSignal := sm.ReadReceived(received)
+			sentSignal := sm.ReadSent(sent)
+
+			for _, id := range tc.sent {
+				e := cetest.FullEvent()
+				e.SetID(id)
+				e.SetExtension("partitionkey", "0")
+				sent <- e
+			}
+			for _, id := range tc.received {
+				e := cetest.FullEvent()
+				e.SetID(id)
+				e.SetExtension("partitionkey", "0")
+				received <- e
+			}
+			close(sent)
+			close(received)
+			<-receivedSignal
+			<-sentSignal
+
+			report := sm.GenerateReport()
+
+			if report.OrderingViolationCount != len(tc.wantViolations) {
+				t.Errorf("want %d ordering violations, got %d", len(tc.wantViolations), report.OrderingViolationCount)
+			}
+			if diff := cmp.Diff(tc.wantViolations, report.OrderingViolationsByPartitionKey["0"].Violations); diff != "" {
+				t.Errorf("(-want, +got) %s", diff)
+			}
+		})
+	}
+}
+
+func TestStateManagerProgress(t *testing.T) {
+
+	sent := make(chan ce.Event, 10)
+	received := make(chan ce.Event, 10)
+
+	sm := NewStateManager(Config{})
+	receivedSignal := sm.ReadReceived(received)
+	sentSignal := sm.ReadSent(sent)
+
+	for _, id := range []string{"1", "2", "3", "4"} {
+		e := cetest.FullEvent()
+		e.SetID(id)
+		sent <- e
+	}
+	for _, id := range []string{"1", "3", "3"} {
+		e := cetest.FullEvent()
+		e.SetID(id)
+		received <- e
+	}
+
+	want := Progress{
+		AcceptedCount:    4,
+		ReceivedCount:    2,
+		OutstandingCount: 2,
+		DuplicateCount:   1,
+	}
+	_ = wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (done bool, err error) {
+		got := sm.Progress()
+		got.OldestOutstandingAge = 0
+		return got == want, nil
+	})
+
+	got := sm.Progress()
+	if got.OldestOutstandingAge <= 0 {
+		t.Errorf("want positive oldest outstanding age, got %v", got.OldestOutstandingAge)
+	}
+	got.OldestOutstandingAge = 0
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got) %s", diff)
+	}
+
+	close(sent)
+	close(received)
+	<-receivedSignal
+	<-sentSignal
+}
+
+func TestStateManagerOutstandingQueue(t *testing.T) {
+
+	sent := make(chan ce.Event, 10)
+	received := make(chan ce.Event, 10)
+
+	sm := NewStateManager(Config{})
+	sentSignal := sm.ReadSent(sent)
+	for i := 0; i < 100; i++ {
+		e := cetest.FullEvent()
+		e.SetID(fmt.Sprint(i))
+		sent <- e
+	}
+	close(sent)
+	<-sentSignal
+
+	// Events are received before the progress is ever requested, so the queue is trimmed on receive.
+	receivedSignal := sm.ReadReceived(received)
+	for i := 0; i < 99; i++ {
+		e := cetest.FullEvent()
+		e.SetID(fmt.Sprint(i))
+		received <- e
+	}
+	close(received)
+	<-receivedSignal
+
+	if diff := cmp.Diff([]string{"99"}, sm.outstandingQueue[sm.outstandingHead:]); diff != "" {
+		t.Errorf("outstanding queue (-want, +got) %s", diff)
+	}
+	if len(sm.outstandingQueue) > 2 {
+		t.Errorf("want compacted outstanding queue, got %d events", len(sm.outstandingQueue))
+	}
+	if got := sm.Progress().OutstandingCount; got != 1 {
+		t.Errorf("want 1 outstanding event, got %d", got)
+	}
+}
+
+func TestStateManagerUnexpectedEvents(t *testing.T) {
+
+	sent := make(chan ce.Event, 10)
+	received := make(chan ce.Event, 10)
+
+	sm := NewStateManager(Config{})
+	receivedSignal := sm.ReadReceived(received)
+	sentSignal := sm.ReadSent(sent)
+
+	for _, id := range []string{"1", "2"} {
+		e := cetest.FullEvent()
+		e.SetID(id)
+		sent <- e
+	}
+	for _, id := range []string{"1", "2", "3", "4", "4", "5"} {
+		e := cetest.FullEvent()
+		e.SetID(id)
+		received <- e
+	}
+	close(sent)
+	close(received)
+	<-receivedSignal
+	<-sentSignal
+
+	sm.Terminated(Metrics{AcceptedCount: 2, RejectedCount: 1, RejectedIDs: []string{"3"}, UncertainCount: 1, UncertainIDs: []string{"5"}})
+	report := sm.GenerateReport()
+
+	if diff := cmp.Diff(map[string][]string{unknownPartitionKey: {"3"}}, report.RejectedReceivedEventsByPartitionKey); diff != "" {
+		t.Errorf("rejected received events (-want, +got) %s", diff)
+	}
+	if report.RejectedReceivedCount != 1 {
+		t.Errorf("want 1 rejected received event, got %d", report.RejectedReceivedCount)
+	}
+	if diff := cmp.Diff(map[string][]string{unknownPartitionKey: {"5"}}, report.UncertainReceivedEventsByPartitionKey); diff != "" {
+		t.Errorf("uncertain received events (-want, +got) %s", diff)
+	}
+	if report.UncertainReceivedCount != 1 {
+		t.Errorf("want 1 uncertain received event, got %d", report.UncertainReceivedCount)
+	}
+	if diff := cmp.Diff(map[string][]string{unknownPartitionKey: {"4"}}, report.UnknownEventsByPartitionKey); diff != "" {
+		t.Errorf("unknown events (-want, +got) %s", diff)
+	}
+	if report.UnknownCount != 1 {
+		t.Errorf("want 1 unknown event, got %d", report.UnknownCount)
+	}
+}
+
+func TestStateManagerOtherRuns(t *testing.T) {
+
+	sent := make(chan ce.Event, 10)
+	received := make(chan ce.Event, 10)
+
+	sm := NewStateManager(Config{RunID: "run"})
+	receivedSignal := sm.ReadReceived(received)
+	sentSignal := sm.ReadSent(sent)
+
+	for _, id := range []string{"1", "2"} {
+		e := cetest.FullEvent()
+		e.SetID(id)
+		e.SetExtension(RunIDExtension, "run")
+		sent <- e
+		received <- e
+	}
+	for i, runID := range []string{"stale", "stale", "other"} {
+		e := cetest.FullEvent()
+		e.SetID(fmt.Sprint(i))
+		e.SetExtension(RunIDExtension, runID)
+		received <- e
+	}
+	close(sent)
+	close(received)
+	<-receivedSignal
+	<-sentSignal
+
+	report := sm.GenerateReport()
+
+	if report.RunID != "run" {
+		t.Errorf("want run ID %q, got %q", "run", report.RunID)
+	}
+	if report.ReceivedCount != 2 || report.DuplicateCount != 0 || report.UnknownCount != 0 {
+		t.Errorf("want 2 received events without duplicates and unknown events, got %d received, %d duplicates and %d unknown",
+			report.ReceivedCount, report.DuplicateCount, report.UnknownCount)
+	}
+	if report.OtherRunsCount != 3 {
+		t.Errorf("want 3 events from other runs, got %d", report.OtherRunsCount)
+	}
+	if diff := cmp.Diff(map[string]int{"stale": 2, "other": 1}, report.OtherRunsEventCounts); diff != "" {
+		t.Errorf("(-want, +got) %s", diff)
+	}
+}
+
+func TestStateManagerPhases(t *testing.T) {
+
+	sent := make(chan ce.Event, 10)
+	received := make(chan ce.Event, 10)
+
+	sm := NewStateManager(Config{})
+	receivedSignal := sm.ReadReceived(received)
+	sentSignal := sm.ReadSent(sent)
+
+	for i, phase := range []string{"steady", "steady", "fault", "fault"} {
+		e := cetest.FullEvent()
+		e.SetID(fmt.Sprint(i))
+		e.SetExtension(PhaseExtension, phase)
+		sent <- e
+		if i != 3 {
+			received <- e
+		}
+		if i == 0 {
+			received <- e
+		}
+	}
+	close(sent)
+	close(received)
+	<-receivedSignal
+	<-sentSignal
+
+	sm.Terminated(Metrics{
+		AcceptedCount: 4,
+		Phases: []PhaseMetrics{
+			{Name: "steady", Metrics: Metrics{AcceptedCount: 2}},
+			{Name: "fault", Metrics: Metrics{AcceptedCount: 2}},
+		},
+	})
+	report := sm.GenerateReport()
+
+	want := []PhaseReport{
+		{Name: "steady", ReceivedCount: 2, DuplicateCount: 1},
+		{Name: "fault", ReceivedCount: 1, LostCount: 1},
+	}
+	if diff := cmp.Diff(want, report.Phases, cmpopts.IgnoreFields(PhaseReport{}, "Metrics")); diff != "" {
+		t.Errorf("(-want, +got) %s", diff)
+	}
+	if report.LostCount != 1 || report.DuplicateCount != 1 {
+		t.Errorf("want 1 lost event and 1 duplicate in total, got %d lost and %d duplicates", report.LostCount, report.DuplicateCount)
+	}
+}
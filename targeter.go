@@ -1,53 +1,178 @@
 package sacura
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"log"
 	"math/rand"
 	"net/http"
 	"time"
 
 	ce "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/binding"
 	ceformat "github.com/cloudevents/sdk-go/v2/binding/format"
 	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
-	cetest "github.com/cloudevents/sdk-go/v2/test"
 	"github.com/google/uuid"
 	vegeta "github.com/tsenart/vegeta/v12/lib"
 )
 
-const CloudEventIdHeader = "Cloudevent-Id"
+const (
+	CloudEventIdHeader = "Cloudevent-Id"
+	// RunIDExtension is the extension holding the ID of the run that sent the event.
+	RunIDExtension = "sacurarunid"
+	// PhaseExtension is the extension holding the name of the scenario phase the event has been sent in.
+	PhaseExtension = "sacuraphase"
+	// TargetExtension is the extension holding the target the event has been sent to, it's only set with multiple
+	// targets.
+	TargetExtension = "sacuratarget"
+)
 
 func NewTargeterGenerator(config Config, newUIID func() uuid.UUID, out chan<- ce.Event) vegeta.Targeter {
+	return newTargeterGenerator(log.Default(), config, "", newUIID, out)
+}
 
-	return func(target *vegeta.Target) error {
+// newTargeterGenerator creates a targeter for the given scenario phase, an empty phase means that no scenario is
+// configured.
+func newTargeterGenerator(logger *log.Logger, config Config, phase string, newUIID func() uuid.UUID, out chan<- ce.Event) vegeta.Targeter {
 
-		id := newUIID().String()
+	newEvent, err := newSenderEventGenerator(config.Sender)
+	if err != nil {
+		return func(*vegeta.Target) error {
+			return fmt.Errorf("failed to create event generator: %w", err)
+		}
+	}
 
-		event := cetest.FullEvent()
-		event.SetID(id)
-		event.SetExtension(BenchmarkTimestampAttribute, fmt.Sprint(time.Now().UnixMilli()))
+	batchSize := 1
+	if config.Sender.Encoding == BatchEncoding {
+		batchSize = config.Sender.BatchSize
+	}
 
-		if config.Ordered != nil {
-			event.SetExtension("partitionkey", fmt.Sprint(rand.Int()%int(config.Ordered.NumPartitionKeys)))
+	pickTarget := newTargetPicker(config.Sender)
+
+	auth, err := newAuthenticator(logger, config.Sender.Auth)
+	if err != nil {
+		return func(*vegeta.Target) error {
+			return fmt.Errorf("failed to create authenticator: %w", err)
 		}
+	}
 
-		hdr := http.Header{}
-		hdr.Set(cehttp.ContentType, ceformat.JSON.MediaType())
-		hdr.Set(CloudEventIdHeader, id)
+	return func(target *vegeta.Target) error {
 
-		body, err := ceformat.JSON.Marshal(&event)
+		url := pickTarget()
+
+		events := make([]ce.Event, 0, batchSize)
+		for i := 0; i < batchSize; i++ {
+			id := newUIID().String()
+
+			event, err := newEvent(id)
+			if errors.Is(err, vegeta.ErrNoTargets) && len(events) > 0 {
+				// The source is exhausted, the last batch is sent with the remaining events.
+				break
+			}
+			if errors.Is(err, vegeta.ErrNoTargets) {
+				return err
+			}
+			if err != nil {
+				return fmt.Errorf("failed to generate event %s: %w", id, err)
+			}
+			event.SetExtension(BenchmarkTimestampAttribute, fmt.Sprint(time.Now().UnixMilli()))
+
+			if config.Ordered != nil {
+				event.SetExtension("partitionkey", fmt.Sprint(rand.Int()%int(config.Ordered.NumPartitionKeys)))
+			}
+			if config.RunID != "" {
+				event.SetExtension(RunIDExtension, config.RunID)
+			}
+			if phase != "" {
+				event.SetExtension(PhaseExtension, phase)
+			}
+			if len(config.Sender.Targets) > 0 {
+				event.SetExtension(TargetExtension, url)
+			}
+			if config.Integrity != nil {
+				stampChecksums(&event)
+			}
+
+			events = append(events, event)
+		}
+
+		hdr, body, err := encode(config.Sender.Encoding, events)
 		if err != nil {
-			return fmt.Errorf("failed to marshal event %v: %w", event, err)
+			return err
+		}
+		for _, e := range events {
+			hdr.Add(CloudEventIdHeader, e.ID())
 		}
+		auth.setHeaders(hdr)
 
 		*target = vegeta.Target{
 			Method: "POST",
-			URL:    config.Sender.Target,
+			URL:    url,
 			Body:   body,
 			Header: hdr,
 		}
 
-		out <- event
+		for _, e := range events {
+			out <- e
+		}
 
 		return nil
 	}
 }
+
+// newSenderEventGenerator creates the EventGenerator for the configured sender source.
+func newSenderEventGenerator(config SenderConfig) (EventGenerator, error) {
+	if f := config.Source.file(); f != nil {
+		source, err := newFileSource(f)
+		if err != nil {
+			return nil, err
+		}
+		return source.eventGenerator(), nil
+	}
+	return NewEventGenerator(config.Event)
+}
+
+// encode encodes events in the given encoding, it returns the request headers and body.
+func encode(encoding Encoding, events []ce.Event) (http.Header, []byte, error) {
+	switch encoding {
+	case BinaryEncoding:
+		req, err := http.NewRequest(http.MethodPost, "", nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := cehttp.WriteRequest(context.Background(), binding.ToMessage(&events[0]), req); err != nil {
+			return nil, nil, fmt.Errorf("failed to write event %v: %w", events[0], err)
+		}
+		var body []byte
+		if req.Body != nil {
+			body, err = ioutil.ReadAll(req.Body)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to read event %v: %w", events[0], err)
+			}
+		}
+		return req.Header, body, nil
+
+	case BatchEncoding:
+		hdr := http.Header{}
+		hdr.Set(cehttp.ContentType, ce.ApplicationCloudEventsBatchJSON)
+
+		body, err := json.Marshal(events)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to marshal events batch: %w", err)
+		}
+		return hdr, body, nil
+
+	default:
+		hdr := http.Header{}
+		hdr.Set(cehttp.ContentType, ceformat.JSON.MediaType())
+
+		body, err := ceformat.JSON.Marshal(&events[0])
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to marshal event %v: %w", events[0], err)
+		}
+		return hdr, body, nil
+	}
+}
@@ -1,6 +1,7 @@
 package sacura
 
 import (
+	"context"
 	"net/http"
 	"testing"
 
@@ -57,3 +58,73 @@ func TestNewTargeterGenerator(t *testing.T) {
 		})
 	}
 }
+
+func TestNewTargeterGeneratorEncoding(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		encoding  Encoding
+		batchSize int
+		wantIDs   int
+	}{
+		{
+			name:     "structured",
+			encoding: StructuredEncoding,
+			wantIDs:  1,
+		},
+		{
+			name:     "binary",
+			encoding: BinaryEncoding,
+			wantIDs:  1,
+		},
+		{
+			name:      "batch",
+			encoding:  BatchEncoding,
+			batchSize: 10,
+			wantIDs:   10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			out := make(chan ce.Event, tt.wantIDs)
+			config := Config{Sender: SenderConfig{
+				Target:    "http://localhost:9090",
+				Encoding:  tt.encoding,
+				BatchSize: tt.batchSize,
+			}, Integrity: &IntegrityConfig{}}
+			f := NewTargeterGenerator(config, uuid.New, out)
+
+			target := &vegeta.Target{}
+			if err := f(target); err != nil {
+				t.Fatal(err)
+			}
+
+			ids := target.Header.Values(CloudEventIdHeader)
+			if len(ids) != tt.wantIDs {
+				t.Fatalf("want %d IDs, got %d", tt.wantIDs, len(ids))
+			}
+
+			req, err := target.Request()
+			if err != nil {
+				t.Fatal(err)
+			}
+			events, err := readEvents(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make([]string, 0, len(events))
+			for _, e := range events {
+				got = append(got, e.ID())
+				if violations := verifyChecksums(&e); len(violations) > 0 {
+					t.Errorf("event %s: unexpected integrity violations %v", e.ID(), violations)
+				}
+			}
+			if diff := cmp.Diff(ids, got); diff != "" {
+				t.Fatal("(-want, +got)", diff)
+			}
+		})
+	}
+}
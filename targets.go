@@ -0,0 +1,94 @@
+package sacura
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+
+	ce "github.com/cloudevents/sdk-go/v2"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+	"go.uber.org/atomic"
+)
+
+// newTargetPicker returns a function picking the target of each request, the returned function is safe for
+// concurrent use.
+func newTargetPicker(config SenderConfig) func() string {
+	targets := config.Targets
+	if len(targets) == 0 {
+		return func() string {
+			return config.Target
+		}
+	}
+
+	if config.TargetSelection == RoundRobinTargetSelection {
+		next := atomic.NewUint64(0)
+		return func() string {
+			return targets[(next.Inc()-1)%uint64(len(targets))].URL
+		}
+	}
+
+	total := 0
+	for _, t := range targets {
+		total += t.Weight
+	}
+	return func() string {
+		n := rand.Intn(total)
+		for _, t := range targets {
+			if n < t.Weight {
+				return t.URL
+			}
+			n -= t.Weight
+		}
+		return targets[len(targets)-1].URL
+	}
+}
+
+// eventTarget returns the target the given event has been sent to, it returns an empty string for events sent
+// without multiple targets.
+func eventTarget(e *ce.Event) string {
+	if v, ok := e.Extensions()[TargetExtension]; ok {
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+// addTargetResult adds the given result to the metrics of the target it has been sent to, it's a no-op when the
+// sender doesn't have multiple targets.
+func (s *sender) addTargetResult(res *vegeta.Result) {
+	if s.targets == nil || res.URL == "" {
+		return
+	}
+	m, ok := s.targets[res.URL]
+	if !ok {
+		m = &Metrics{}
+		s.targets[res.URL] = m
+	}
+
+	n := len(res.RequestHeaders.Values(CloudEventIdHeader))
+	m.ProposedCount += n
+	if isAccepted(res) {
+		m.AcceptedCount += n
+	} else if res.Code != 0 {
+		m.RejectedCount += n
+	} else {
+		m.UncertainCount += n
+	}
+	m.Metrics.Add(res)
+}
+
+// targetMetrics returns the metrics of each target sorted by target, it returns nil when the sender doesn't have
+// multiple targets.
+func (s *sender) targetMetrics() []TargetMetrics {
+	if s.targets == nil {
+		return nil
+	}
+	metrics := make([]TargetMetrics, 0, len(s.targets))
+	for target, m := range s.targets {
+		m.Metrics.Close()
+		metrics = append(metrics, TargetMetrics{Target: target, Metrics: *m})
+	}
+	sort.Slice(metrics, func(i, j int) bool {
+		return metrics[i].Target < metrics[j].Target
+	})
+	return metrics
+}
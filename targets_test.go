@@ -0,0 +1,67 @@
+package sacura
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewTargetPicker(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		config SenderConfig
+		want   []string
+	}{
+		{
+			name:   "single target",
+			config: SenderConfig{Target: "http://a"},
+			want:   []string{"http://a", "http://a", "http://a"},
+		},
+		{
+			name: "round robin",
+			config: SenderConfig{
+				Targets:         []TargetConfig{{URL: "http://a", Weight: 1}, {URL: "http://b", Weight: 5}},
+				TargetSelection: RoundRobinTargetSelection,
+			},
+			want: []string{"http://a", "http://b", "http://a", "http://b"},
+		},
+		{
+			name: "weighted",
+			config: SenderConfig{
+				Targets:         []TargetConfig{{URL: "http://a", Weight: 1}},
+				TargetSelection: WeightedTargetSelection,
+			},
+			want: []string{"http://a", "http://a", "http://a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pick := newTargetPicker(tt.config)
+			got := make([]string, 0, len(tt.want))
+			for range tt.want {
+				got = append(got, pick())
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("(-want, +got) %s", diff)
+			}
+		})
+	}
+}
+
+func TestNewTargetPickerWeights(t *testing.T) {
+	pick := newTargetPicker(SenderConfig{
+		Targets:         []TargetConfig{{URL: "http://a", Weight: 1}, {URL: "http://b", Weight: 3}},
+		TargetSelection: WeightedTargetSelection,
+	})
+
+	counts := make(map[string]int, 2)
+	n := 10000
+	for i := 0; i < n; i++ {
+		counts[pick()]++
+	}
+	if p := float64(counts["http://b"]) / float64(n); p < 0.7 || p > 0.8 {
+		t.Errorf("want target http://b picked about 75%% of the times, got %.2f%% (%v)", p*100, counts)
+	}
+}
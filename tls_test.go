@@ -0,0 +1,190 @@
+package sacura
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	ce "github.com/cloudevents/sdk-go/v2"
+	cetest "github.com/cloudevents/sdk-go/v2/test"
+)
+
+func TestReceiverTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := writeTestCertificate(t, dir, "ca", nil, nil)
+	writeTestCertificate(t, dir, "server", ca, caKey)
+	writeTestCertificate(t, dir, "client", ca, caKey)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	config := &ReceiverConfig{
+		Port: 9203,
+		TLS: &ReceiverTLSConfig{
+			CertFile:     filepath.Join(dir, "server.crt"),
+			KeyFile:      filepath.Join(dir, "server.key"),
+			ClientCAFile: filepath.Join(dir, "ca.crt"),
+		},
+	}
+	received := make(chan ce.Event, 1)
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- startReceiver(ctx, log.Default(), config, nil, nil, func(_ context.Context, e *ce.Event, _ *http.Request) error {
+			received <- *e
+			return nil
+		})
+	}()
+
+	tests := []struct {
+		name           string
+		config         SenderTLSConfig
+		wantErr        bool
+		wantProtoMajor int
+	}{
+		{
+			name: "client certificate",
+			config: SenderTLSConfig{
+				CAFile:   filepath.Join(dir, "ca.crt"),
+				CertFile: filepath.Join(dir, "client.crt"),
+				KeyFile:  filepath.Join(dir, "client.key"),
+			},
+			wantProtoMajor: 2,
+		},
+		{
+			name: "no client certificate",
+			config: SenderTLSConfig{
+				CAFile: filepath.Join(dir, "ca.crt"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown CA",
+			config: SenderTLSConfig{
+				CertFile: filepath.Join(dir, "client.crt"),
+				KeyFile:  filepath.Join(dir, "client.key"),
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tlsConfig, err := tt.config.tlsConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			client := http.Client{
+				Transport: &http.Transport{TLSClientConfig: tlsConfig, ForceAttemptHTTP2: true},
+				Timeout:   5 * time.Second,
+			}
+
+			e := cetest.FullEvent()
+			hdr, body, err := encode(StructuredEncoding, []ce.Event{e})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var resp *http.Response
+			for i := 0; i < 50; i++ {
+				req, _ := http.NewRequest(http.MethodPost, "https://localhost:9203", strings.NewReader(string(body)))
+				req.Header = hdr
+				resp, err = client.Do(req)
+				if err == nil || !isConnectionRefused(err) {
+					break
+				}
+				// The receiver might not be started yet.
+				time.Sleep(100 * time.Millisecond)
+			}
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("want status code %d, got %d", http.StatusOK, resp.StatusCode)
+			}
+			if resp.ProtoMajor != tt.wantProtoMajor {
+				t.Errorf("want HTTP/%d, got %s", tt.wantProtoMajor, resp.Proto)
+			}
+			if got := <-received; got.ID() != e.ID() {
+				t.Errorf("want event %s, got %s", e.ID(), got.ID())
+			}
+		})
+	}
+
+	cancel()
+	if err := <-errChan; err != nil {
+		t.Error(err)
+	}
+}
+
+func isConnectionRefused(err error) bool {
+	return strings.Contains(err.Error(), "connection refused")
+}
+
+// writeTestCertificate writes name.crt and name.key to dir, the certificate is a CA when parent is nil, otherwise
+// it's signed by parent.
+func writeTestCertificate(t *testing.T, dir string, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writePEM := func(path string, blockType string, b []byte) {
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: b}), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writePEM(filepath.Join(dir, name+".crt"), "CERTIFICATE", der)
+	writePEM(filepath.Join(dir, name+".key"), "EC PRIVATE KEY", keyDer)
+
+	return cert, key
+}